@@ -0,0 +1,129 @@
+package backtesting
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+type (
+	//Account 同一基金上运行多个策略的账户，如核心定投加上逢低买入的计划。
+	//各策略共用一个资金池，卖出的资金留在账户中，供任一策略之后的买入使用，不足时才投入新的本金。
+	//持仓合并计算，每个策略只卖出自己买入的份额。同一日按策略顺序依次交易
+	Account struct {
+		nws     NetWorthList //净值列表
+		names   []string     //策略名称
+		engines []*Engine    //各策略的引擎，记录该策略的交易及归属的份额、本金和余额
+		balance float32      //共用的余额
+		result  Result       //合并的结果
+	}
+	//AccountResult 账户运行结果
+	AccountResult struct {
+		Result              //合并的结果，交易记录按策略名称标记
+		Strategies []Result //各策略的结果，顺序与策略一致。余额为负表示使用了其他策略卖出的资金
+	}
+)
+
+//NewAccount 创建多策略账户。策略未设置名称时依次命名为策略1、策略2
+func NewAccount(nws NetWorthList, strategies ...Strategy) *Account {
+	a := &Account{nws: nws}
+	for k, s := range strategies {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("策略%d", k+1)
+		}
+		a.names = append(a.names, name)
+		//各策略的统计天数不同，使用净值列表的副本
+		a.engines = append(a.engines, NewEngine(s, append(NetWorthList(nil), nws...)))
+	}
+	return a
+}
+
+//Run 运行账户。在任一策略的开始和截止日期之间，分红及拆分对所有持仓生效，交易只在各自的日期范围内进行。
+//策略的投资周期配置错误时记录日志，不进行交易
+func (a *Account) Run() AccountResult {
+	for k, e := range a.engines {
+		if err := e.strategy.Validate(); err != nil {
+			log.Printf("策略%s的投资周期配置错误，不进行交易: %v", a.names[k], err)
+			r := a.results()
+			r.Strategies[k].Err = err
+			r.Err = fmt.Errorf("策略%s: %v", a.names[k], err)
+			return r
+		}
+	}
+	start, end := a.span()
+	for i, base := range a.nws {
+		if DiffDays(base.Date, start) < 0 || DiffDays(base.Date, end) > 0 {
+			continue
+		}
+		for k, e := range a.engines {
+			a.runToday(k, e.nws[i])
+		}
+		a.refresh(base)
+	}
+	return a.results()
+}
+
+//span 所有策略的最早开始日期和最晚截止日期
+func (a *Account) span() (start, end time.Time) {
+	for k, e := range a.engines {
+		if s := e.strategy; k == 0 || s.StartDate.Before(start) {
+			start = s.StartDate
+		}
+		if s := e.strategy; k == 0 || s.EndDate.After(end) {
+			end = s.EndDate
+		}
+	}
+	return start, end
+}
+
+func (a *Account) runToday(k int, nw NetWorth) {
+	e := a.engines[k]
+	n := len(e.trans)
+	e.begin(nw)
+	if nw.Splits > 0 {
+		e.spilit(nw)
+	}
+	e.serve(nw)
+	e.accrue(nw)
+	if nw.Dividends > 0 {
+		e.dividends(nw)
+	} else if DiffDays(nw.Date, e.strategy.StartDate) >= 0 && DiffDays(nw.Date, e.strategy.EndDate) <= 0 {
+		//交易时使用共用的余额，结束后将余额的变化归属到该策略
+		own := e.balance
+		e.balance = a.balance
+		e.trade(nw)
+		own += e.balance - a.balance
+		a.balance, e.balance = e.balance, own
+	}
+	e.refresh(nw)
+	for i := n; i < len(e.trans); i++ {
+		e.trans[i].Strategy = a.names[k]
+		a.result.TransList.Append(e.trans[i])
+	}
+}
+
+func (a *Account) refresh(nw NetWorth) {
+	r := &a.result
+	r.Date, r.Nav, r.Balance = nw.Date, nw.NAV, a.balance
+	r.Invest, r.Shares, r.ServiceFee = 0, 0, 0
+	for _, e := range a.engines {
+		r.Invest += e.invest
+		r.Shares += e.shares
+		r.ServiceFee += e.serviceFee
+	}
+	r.Value = r.Shares*r.Nav + r.Balance
+	r.Profit = r.Value - r.Invest
+	r.Rop = r.Profit / r.Invest * 100
+}
+
+func (a *Account) results() AccountResult {
+	r := AccountResult{Result: a.result}
+	for _, e := range a.engines {
+		item := e.result()
+		r.Rejects = append(r.Rejects, item.Rejects...)
+		r.Skipped = append(r.Skipped, item.Skipped...)
+		r.Strategies = append(r.Strategies, item)
+	}
+	return r
+}
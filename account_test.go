@@ -0,0 +1,54 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountSingle(t *testing.T) {
+	s, nws := sweepStrategy()
+	expect := NewEngine(s, append(NetWorthList(nil), nws...)).Run()
+	r := NewAccount(nws, s).Run()
+	assert.Equal(t, expect.Value, r.Value)
+	assert.Equal(t, expect.Invest, r.Invest)
+	assert.Equal(t, expect.Balance, r.Balance)
+	assert.Len(t, r.TransList, len(expect.TransList))
+	assert.Equal(t, "策略1", r.TransList[0].Strategy)
+}
+
+func TestAccount(t *testing.T) {
+	s, nws := sweepStrategy()
+	core := s
+	core.Name, core.FixedMethod, core.Disable = "核心定投", FixedInvest, RuleSell|RuleAppend
+	dip := s
+	dip.Name, dip.CycleValue, dip.BasicAmount = "逢低买入", 20, 500
+	r := NewAccount(nws, core, dip).Run()
+	assert.Len(t, r.Strategies, 2)
+
+	var invest, shares, value float32
+	var trans int
+	for k, item := range r.Strategies {
+		invest += item.Invest
+		shares += item.Shares
+		value += item.Value
+		trans += len(item.TransList)
+		//每个策略只卖出自己的份额
+		assert.True(t, item.Shares >= 0)
+		for _, tr := range item.TransList {
+			assert.Equal(t, []string{"核心定投", "逢低买入"}[k], tr.Strategy)
+		}
+	}
+	assert.Equal(t, invest, r.Invest)
+	assert.Equal(t, shares, r.Shares)
+	assert.InDelta(t, value, r.Value, 0.1)
+	assert.Len(t, r.TransList, trans)
+	assert.Nil(t, r.Strategies[0].TransList.LastSell())
+	assert.NotNil(t, r.Strategies[1].TransList.LastSell())
+
+	//卖出的资金由核心定投继续使用，少投入本金
+	alone := NewEngine(core, append(NetWorthList(nil), nws...)).Run().Invest + NewEngine(dip, append(NetWorthList(nil), nws...)).Run().Invest
+	assert.True(t, r.Invest < alone)
+	assert.True(t, r.Strategies[0].Balance < 0)
+	assert.False(t, r.XIRR() == 0)
+}
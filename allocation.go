@@ -0,0 +1,371 @@
+package backtesting
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type (
+	//AllocModel 资产配置模型
+	AllocModel int
+	//Allocation 动态配置参数
+	Allocation struct {
+		Model    AllocModel //配置模型
+		Lookback int        //回看的交易日数
+		Cycle    int        //调整周期（月）
+		RiskFree float64    //无风险年化收益率(%)，最大夏普模型使用
+	}
+)
+
+const (
+	//AllocEqual 等权重
+	AllocEqual AllocModel = 1
+	//AllocInverseVol 波动率倒数加权
+	AllocInverseVol AllocModel = 2
+	//AllocRiskParity 风险平价
+	AllocRiskParity AllocModel = 3
+	//AllocMinVariance 最小方差
+	AllocMinVariance AllocModel = 4
+	//AllocMaxSharpe 最大夏普
+	AllocMaxSharpe AllocModel = 5
+)
+
+//Weights 根据收益率计算目标权重。returns[i]为第i个标的按日对齐的涨跌幅(%)，返回权重之和为1
+func (m AllocModel) Weights(returns [][]float64, riskFree float64) []float64 {
+	n := len(returns)
+	if n == 0 {
+		return nil
+	}
+	if m == AllocEqual || len(returns[0]) < 2 {
+		return equalWeights(n)
+	}
+	cov := covariance(returns)
+	switch m {
+	case AllocInverseVol:
+		return inverseVolWeights(cov)
+	case AllocRiskParity:
+		return riskParityWeights(cov)
+	case AllocMinVariance:
+		return minVarianceWeights(cov)
+	case AllocMaxSharpe:
+		mu := make([]float64, n)
+		for i, r := range returns {
+			mu[i] = mean(r)
+		}
+		//年化无风险收益折算为日收益(%)
+		return maxSharpeWeights(mu, cov, riskFree/252)
+	}
+	return equalWeights(n)
+}
+
+//isReweightDay 是否为重新计算权重的日期
+func (e *PackEngine) isReweightDay(now time.Time) bool {
+	if e.alloc == nil {
+		return false
+	}
+	if e.reweighted.IsZero() {
+		return true
+	}
+	cycle := e.alloc.Cycle
+	if cycle <= 0 {
+		cycle = 1
+	}
+	return DiffDays(now, e.reweighted.AddDate(0, cycle, 0)) >= 0
+}
+
+//reweight 按回看窗口重新计算各项目的分配比例。只使用当日之前的净值，避免未来数据
+func (e *PackEngine) reweight() {
+	returns := e.lookback(e.alloc.Lookback)
+	if returns == nil {
+		return
+	}
+	e.reweighted = e.now
+	weights := e.alloc.Model.Weights(returns, e.alloc.RiskFree)
+	total := 0
+	for _, item := range e.items {
+		total += item.Precent
+	}
+	for k, p := range apportion(weights, total) {
+		e.items[k].Precent = p
+	}
+	e.allot()
+	e.rebalance()
+}
+
+//lookback 获取各项目在当日之前共同交易日的涨跌幅
+func (e *PackEngine) lookback(days int) [][]float64 {
+	if days <= 0 {
+		days = 250
+	}
+	rocs := make([]map[string]float64, len(e.items))
+	counts := map[string]int{}
+	for k, item := range e.items {
+		rocs[k] = map[string]float64{}
+		end := len(item.nws)
+		for i, nw := range item.nws {
+			if DiffDays(nw.Date, e.now) >= 0 {
+				end = i
+				break
+			}
+		}
+		start := end - days
+		if start < 0 {
+			start = 0
+		}
+		for _, nw := range item.nws[start:end] {
+			key := DateToString(nw.Date)
+			rocs[k][key] = float64(nw.ROC)
+			counts[key]++
+		}
+	}
+	var dates []string
+	for key, c := range counts {
+		if c == len(e.items) {
+			dates = append(dates, key)
+		}
+	}
+	if len(dates) < 2 {
+		return nil
+	}
+	sort.Strings(dates)
+	returns := make([][]float64, len(e.items))
+	for k := range e.items {
+		returns[k] = make([]float64, len(dates))
+		for i, key := range dates {
+			returns[k][i] = rocs[k][key]
+		}
+	}
+	return returns
+}
+
+//allot 根据分配比例设置各项目的投入金额
+func (e *PackEngine) allot() {
+	now := e.now
+	if now.IsZero() {
+		now = e.startDate
+	}
+	//尚未成立的项目不分配，比例按已成立项目的比例分摊
+	var total, listed int
+	e.listing = 0
+	for _, item := range e.items {
+		total += item.Precent
+		if item.listed(now) {
+			listed += item.Precent
+			e.listing++
+		}
+	}
+	for _, item := range e.items {
+		precent := float32(item.Precent)
+		if !item.listed(now) {
+			precent = 0
+		} else if listed > 0 && listed < total {
+			precent = precent * float32(total) / float32(listed)
+		}
+		item.strategy.BasicAmount = e.amount * precent / 100
+		item.strategy.MaxAmount = item.strategy.BasicAmount * 10
+	}
+}
+
+//apportion 将权重按最大余额法分配为整数比例，保证总和不变
+func apportion(weights []float64, total int) []int {
+	ps := make([]int, len(weights))
+	rests := make([]int, len(weights))
+	sum := 0
+	for i, w := range weights {
+		v := w * float64(total)
+		ps[i] = int(math.Floor(v))
+		sum += ps[i]
+		rests[i] = i
+	}
+	sort.SliceStable(rests, func(a, b int) bool {
+		ra := weights[rests[a]]*float64(total) - float64(ps[rests[a]])
+		rb := weights[rests[b]]*float64(total) - float64(ps[rests[b]])
+		return ra > rb
+	})
+	for i := 0; i < total-sum && i < len(rests); i++ {
+		ps[rests[i]]++
+	}
+	return ps
+}
+
+func equalWeights(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1 / float64(n)
+	}
+	return w
+}
+
+func inverseVolWeights(cov [][]float64) []float64 {
+	w := make([]float64, len(cov))
+	for i := range cov {
+		if cov[i][i] <= 0 {
+			return equalWeights(len(cov))
+		}
+		w[i] = 1 / math.Sqrt(cov[i][i])
+	}
+	return normalize(w)
+}
+
+//riskParityWeights 风险平价，各标的风险贡献相等
+func riskParityWeights(cov [][]float64) []float64 {
+	w := inverseVolWeights(cov)
+	n := float64(len(w))
+	for iter := 0; iter < 500; iter++ {
+		mrc := mulVec(cov, w)
+		variance := dot(w, mrc)
+		if variance <= 0 {
+			return w
+		}
+		for i := range w {
+			rc := w[i] * mrc[i]
+			if rc <= 0 {
+				continue
+			}
+			w[i] *= math.Sqrt(variance / n / rc)
+		}
+		w = normalize(w)
+	}
+	return w
+}
+
+//minVarianceWeights 最小方差，不允许做空
+func minVarianceWeights(cov [][]float64) []float64 {
+	w := equalWeights(len(cov))
+	var trace float64
+	for i := range cov {
+		trace += cov[i][i]
+	}
+	if trace <= 0 {
+		return w
+	}
+	step := 1 / (2 * trace)
+	for iter := 0; iter < 2000; iter++ {
+		grad := mulVec(cov, w)
+		for i := range w {
+			w[i] -= step * 2 * grad[i]
+		}
+		w = simplex(w)
+	}
+	return w
+}
+
+//maxSharpeWeights 最大夏普，不允许做空
+func maxSharpeWeights(mu []float64, cov [][]float64, rf float64) []float64 {
+	sharpe := func(w []float64) float64 {
+		v := dot(w, mulVec(cov, w))
+		if v <= 0 {
+			return math.Inf(-1)
+		}
+		return (dot(mu, w) - rf) / math.Sqrt(v)
+	}
+	w := minVarianceWeights(cov)
+	best := sharpe(w)
+	if math.IsInf(best, -1) {
+		return w
+	}
+	step := 0.1
+	for iter := 0; iter < 500 && step > 1e-8; iter++ {
+		sw := mulVec(cov, w)
+		sd := math.Sqrt(dot(w, sw))
+		excess := dot(mu, w) - rf
+		next := make([]float64, len(w))
+		for i := range w {
+			g := mu[i]/sd - excess*sw[i]/(sd*sd*sd)
+			next[i] = w[i] + step*g
+		}
+		next = simplex(next)
+		if s := sharpe(next); s > best {
+			w, best = next, s
+			step *= 1.2
+		} else {
+			step *= 0.5
+		}
+	}
+	return w
+}
+
+//simplex 将向量投影到权重非负且和为1的集合
+func simplex(v []float64) []float64 {
+	u := append([]float64(nil), v...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(u)))
+	var sum, theta float64
+	for i, x := range u {
+		sum += x
+		t := (sum - 1) / float64(i+1)
+		if x-t > 0 {
+			theta = t
+		}
+	}
+	w := make([]float64, len(v))
+	for i, x := range v {
+		w[i] = math.Max(x-theta, 0)
+	}
+	return w
+}
+
+func normalize(w []float64) []float64 {
+	var sum float64
+	for _, x := range w {
+		sum += x
+	}
+	if sum <= 0 {
+		return equalWeights(len(w))
+	}
+	for i := range w {
+		w[i] /= sum
+	}
+	return w
+}
+
+func covariance(returns [][]float64) [][]float64 {
+	n := len(returns)
+	mus := make([]float64, n)
+	for i, r := range returns {
+		mus[i] = mean(r)
+	}
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+	}
+	t := len(returns[0])
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var c float64
+			for k := 0; k < t; k++ {
+				c += (returns[i][k] - mus[i]) * (returns[j][k] - mus[j])
+			}
+			c /= float64(t - 1)
+			cov[i][j], cov[j][i] = c, c
+		}
+	}
+	return cov
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func mulVec(m [][]float64, v []float64) []float64 {
+	r := make([]float64, len(m))
+	for i := range m {
+		r[i] = dot(m[i], v)
+	}
+	return r
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
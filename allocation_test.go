@@ -0,0 +1,86 @@
+package backtesting
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomReturns(seed int64, vols []float64, means []float64, days int) [][]float64 {
+	r := rand.New(rand.NewSource(seed))
+	returns := make([][]float64, len(vols))
+	for i := range vols {
+		returns[i] = make([]float64, days)
+		for t := range returns[i] {
+			returns[i][t] = means[i] + r.NormFloat64()*vols[i]
+		}
+	}
+	return returns
+}
+
+func TestAllocModelWeights(t *testing.T) {
+	returns := randomReturns(1, []float64{0.5, 1, 2}, []float64{0.01, 0.02, 0.03}, 500)
+	for _, m := range []AllocModel{AllocEqual, AllocInverseVol, AllocRiskParity, AllocMinVariance, AllocMaxSharpe} {
+		w := m.Weights(returns, 3)
+		assert.Len(t, w, 3)
+		var sum float64
+		for _, x := range w {
+			assert.True(t, x >= 0)
+			sum += x
+		}
+		assert.InDelta(t, 1, sum, 1e-6, "模型%d", m)
+	}
+	w := AllocInverseVol.Weights(returns, 0)
+	assert.True(t, w[0] > w[1] && w[1] > w[2])
+	rp := AllocRiskParity.Weights(returns, 0)
+	assert.InDelta(t, w[0], rp[0], 0.05)
+	mv := AllocMinVariance.Weights(returns, 0)
+	assert.True(t, mv[0] > 0.6)
+
+	//收益率相同时，最大夏普应偏向低波动
+	returns = randomReturns(2, []float64{0.5, 2}, []float64{0.2, 0.2}, 500)
+	ms := AllocMaxSharpe.Weights(returns, 0)
+	assert.True(t, ms[0] > ms[1])
+}
+
+func TestApportion(t *testing.T) {
+	ps := apportion([]float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, 80)
+	assert.Equal(t, 80, ps[0]+ps[1]+ps[2])
+	assert.Equal(t, []int{27, 27, 26}, ps)
+}
+
+func TestPackEngineReweight(t *testing.T) {
+	start := ParseDate("2020-01-01")
+	returns := randomReturns(3, []float64{0.5, 2}, []float64{0, 0}, 300)
+	var items PackItemList
+	for _, rocs := range returns {
+		var nws NetWorthList
+		nav := 1.0
+		for i, roc := range rocs {
+			nav *= 1 + roc/100
+			nws = append(nws, NetWorth{Date: start.AddDate(0, 0, i), NAV: float32(nav), ROC: float32(roc)})
+		}
+		items = append(items, PackItem{
+			Engine:  NewEngine(Strategy{CycleType: CycleMonth, CycleValue: 1, VolaDays: 20}, nws),
+			TOF:     Radical,
+			Precent: 50,
+		})
+	}
+	e := NewPackEngine(items, start.AddDate(0, 0, 200), 1000)
+	e.SetAllocation(Allocation{Model: AllocInverseVol, Lookback: 120, Cycle: 3})
+	e.now = e.startDate
+	assert.True(t, e.isReweightDay(e.now))
+	e.reweight()
+	assert.Equal(t, 100, e.items[0].Precent+e.items[1].Precent)
+	assert.True(t, e.items[0].Precent > 70)
+	assert.Equal(t, float32(e.items[0].Precent)*10, e.items[0].strategy.BasicAmount)
+	assert.False(t, e.isReweightDay(e.now.AddDate(0, 2, 0)))
+	assert.True(t, e.isReweightDay(e.now.AddDate(0, 3, 0)))
+
+	//回看窗口不得包含当日及之后的数据
+	returnsAt := e.lookback(1000)
+	assert.Len(t, returnsAt[0], 200)
+	assert.True(t, math.Abs(returnsAt[0][199]-float64(items[0].nws[199].ROC)) < 1e-6)
+}
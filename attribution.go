@@ -0,0 +1,145 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+type (
+	//Attribution 收益归因。买入类按持有到期计算收益，卖出类按相对持有到期多得的金额计算
+	Attribution struct {
+		Code      string
+		Fixed     float32 //定投买入的收益
+		Append    float32 //追加买入的收益
+		Dividends float32 //分红再投资的收益
+		Sell      float32 //卖出相对持有到期的收益
+		Switch    float32 //转换的收益，转入按买入计算，转出按卖出计算
+		Withdraw  float32 //取出相对持有到期的收益
+		Other     float32 //其它收益，如货币基金收益结转及计算误差
+		Profit    float32 //总收益
+	}
+	//AttributionList 归因列表
+	AttributionList []Attribution
+	//Counterfactual 关闭规则后的对比结果
+	Counterfactual struct {
+		Rule   Rule
+		Result Result
+		Delta  float32 //实际收益减去关闭规则后的收益，正数表示该规则带来了收益
+	}
+	//PackCounterfactual 组合关闭规则后的对比结果
+	PackCounterfactual struct {
+		Rule   Rule
+		Result PackResult
+		Delta  float32 //实际收益减去关闭规则后的收益，正数表示该规则带来了收益
+	}
+)
+
+//Attribute 按交易类型归因当前收益
+func (ctx *Engine) Attribute() Attribution {
+	a := Attribution{
+		Code:   ctx.strategy.Code,
+		Profit: ctx.profit,
+	}
+	for _, t := range ctx.trans {
+		//持有到期的价值，按之后的拆分折算份额
+		held := t.Shares * ctx.splitFactor(t.Date) * ctx.nav
+		switch t.TransType {
+		case TransFixed, TransContribute:
+			a.Fixed += held - t.Amount
+		case TransAppend:
+			a.Append += held - t.Amount
+		case TransDividends:
+			a.Dividends += held
+		case TransSell:
+			a.Sell += t.Amount - t.TransFee - held
+		case TransWithdraw:
+			a.Withdraw += t.Amount - t.TransFee - held
+		case TransSwitch:
+			a.Switch += held - t.Amount
+		}
+	}
+	a.Other = a.Profit - a.Fixed - a.Append - a.Dividends - a.Sell - a.Switch - a.Withdraw
+	return a
+}
+
+//splitFactor 指定日期之后至当前日期的拆分倍数
+func (ctx *Engine) splitFactor(date time.Time) float32 {
+	var factor float32 = 1
+	for _, nw := range ctx.nws {
+		if nw.Splits > 0 && DiffDays(nw.Date, date) > 0 && DiffDays(nw.Date, ctx.date) <= 0 {
+			factor *= nw.Splits
+		}
+	}
+	return factor
+}
+
+//Attribute 组合各项目的收益归因
+func (e *PackEngine) Attribute() AttributionList {
+	items := make(AttributionList, 0, len(e.items))
+	for _, item := range e.items {
+		items = append(items, item.Attribute())
+	}
+	return items
+}
+
+//Sum 合计归因
+func (items AttributionList) Sum() Attribution {
+	var a Attribution
+	for _, item := range items {
+		a.Fixed += item.Fixed
+		a.Append += item.Append
+		a.Dividends += item.Dividends
+		a.Sell += item.Sell
+		a.Switch += item.Switch
+		a.Withdraw += item.Withdraw
+		a.Other += item.Other
+		a.Profit += item.Profit
+	}
+	return a
+}
+
+//Disable 关闭组合内所有项目的规则
+func (e *PackEngine) Disable(rule Rule) {
+	for _, item := range e.items {
+		item.strategy.Disable |= rule
+	}
+}
+
+//Counterfactuals 分别关闭每条规则重新回测，对比规则带来的收益
+func Counterfactuals(s Strategy, nws NetWorthList, rules ...Rule) (Result, []Counterfactual) {
+	actual := NewEngine(s, nws).Run()
+	items := make([]Counterfactual, 0, len(rules))
+	for _, rule := range rules {
+		st := s
+		st.Disable |= rule
+		result := NewEngine(st, nws).Run()
+		items = append(items, Counterfactual{
+			Rule:   rule,
+			Result: result,
+			Delta:  actual.Profit - result.Profit,
+		})
+	}
+	return actual, items
+}
+
+//PackCounterfactuals 分别关闭每条规则重新运行组合，build每次需创建新的组合
+func PackCounterfactuals(build func() *PackEngine, rules ...Rule) (PackResult, []PackCounterfactual) {
+	run := func(rule Rule) PackResult {
+		e := build()
+		e.Disable(rule)
+		e.SetLogger(log.New(ioutil.Discard, "", 0))
+		return e.Run()
+	}
+	actual := run(0)
+	items := make([]PackCounterfactual, 0, len(rules))
+	for _, rule := range rules {
+		result := run(rule)
+		items = append(items, PackCounterfactual{
+			Rule:   rule,
+			Result: result,
+			Delta:  actual.Profit - result.Profit,
+		})
+	}
+	return actual, items
+}
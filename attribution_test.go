@@ -0,0 +1,104 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//waveNws 周期涨跌的净值，每隔一段时间出现单日大跌，并包含一次分红和一次拆分
+func waveNws(start string, days int) NetWorthList {
+	var nws NetWorthList
+	date := ParseDate(start)
+	last := float32(1)
+	for i := 0; i < days; i++ {
+		nav := float32(1 + 0.4*math.Sin(float64(i)/40))
+		if i%50 == 25 {
+			nav *= 0.95
+		}
+		nw := NetWorth{Date: date.AddDate(0, 0, i), NAV: nav, CNAV: nav, ROC: (nav - last) / last * 100}
+		if i == days/5 {
+			nw.Dividends = 0.05
+		}
+		if i == days/2 {
+			nw.Splits = 2
+			nw.NAV /= 2
+		}
+		nws = append(nws, nw)
+		last = nav
+	}
+	return nws
+}
+
+func waveStrategy(start, end string) Strategy {
+	return Strategy{
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		SellPoint:   20,
+		StartDate:   ParseDate(start),
+		EndDate:     ParseDate(end),
+		TransRate:   0.15,
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		VolaDays:    60,
+		FixedMethod: FloatInvest,
+	}
+}
+
+func TestEngineAttribute(t *testing.T) {
+	e := NewEngine(waveStrategy("2020-01-01", "2022-12-31"), waveNws("2020-01-01", 1000))
+	result := e.Run()
+	a := e.Attribute()
+	assert.Equal(t, result.Profit, a.Profit)
+	assert.NotZero(t, a.Fixed)
+	assert.NotZero(t, a.Append)
+	assert.NotZero(t, a.Sell)
+	assert.NotZero(t, a.Dividends)
+	//除份额取整外，收益全部可以归因到交易
+	assert.InDelta(t, 0, a.Other, float64(result.Invest)/1000)
+}
+
+func TestCounterfactuals(t *testing.T) {
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	actual, items := Counterfactuals(s, waveNws("2020-01-01", 1000), RuleAppend, RuleSell)
+	assert.Len(t, items, 2)
+	assert.Nil(t, items[0].Result.TransList.LastByType(TransAppend))
+	assert.NotNil(t, items[0].Result.TransList.LastSell())
+	assert.Nil(t, items[1].Result.TransList.LastSell())
+	for _, item := range items {
+		assert.Equal(t, actual.Profit-item.Result.Profit, item.Delta)
+	}
+}
+
+func TestPackAttribute(t *testing.T) {
+	build := func() *PackEngine {
+		items := PackItemList{
+			{Engine: NewEngine(waveStrategy("2020-01-01", "2022-12-31"), waveNws("2020-01-01", 1000)), TOF: Radical, Precent: 50},
+			{Engine: NewEngine(waveStrategy("2020-01-01", "2022-12-31"), flatNws("2020-01-01", 1000, 1)), TOF: Conservative, Precent: 50},
+		}
+		e := NewPackEngine(items, ParseDate("2020-01-01"), 2000)
+		e.SetEndDate(ParseDate("2022-06-30"))
+		return e
+	}
+	e := build()
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	result := e.Run()
+	items := e.Attribute()
+	assert.Len(t, items, 2)
+	sum := items.Sum()
+	var profit float32
+	for _, item := range result.Items {
+		profit += item.Profit
+	}
+	assert.InDelta(t, profit, sum.Profit, 0.01)
+	assert.InDelta(t, 0, sum.Other, float64(result.Invest)/1000)
+
+	actual, cfs := PackCounterfactuals(build, RuleAppend)
+	assert.InDelta(t, result.Profit, actual.Profit, 0.01)
+	assert.Len(t, cfs, 1)
+	assert.Nil(t, cfs[0].Result.TransList.LastByType(TransAppend))
+}
@@ -0,0 +1,180 @@
+package backtesting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	//TradeStatus 申购或赎回状态
+	TradeStatus int
+	//Availability 基金交易状态，从Date起生效直到下一条记录
+	Availability struct {
+		Date      time.Time
+		Subscribe TradeStatus //申购状态
+		Redeem    TradeStatus //赎回状态
+		Limit     float32     //限大额时每日累计申购上限，为0时不限制
+	}
+	//AvailabilityList 按日期排序的交易状态
+	AvailabilityList []Availability
+)
+
+const (
+	//StatusOpen 开放
+	StatusOpen TradeStatus = 0
+	//StatusLimited 限大额
+	StatusLimited TradeStatus = 1
+	//StatusSuspended 暂停
+	StatusSuspended TradeStatus = 2
+)
+
+//ParseStatus 解析天天基金的申购、赎回状态文字，如"开放申购"、"限大额"、"暂停申购"、"封闭期"
+func ParseStatus(text string) TradeStatus {
+	switch {
+	case strings.Contains(text, "限"), strings.Contains(text, "大额"):
+		return StatusLimited
+	case strings.Contains(text, "暂停"), strings.Contains(text, "封闭"), strings.Contains(text, "认购"), strings.Contains(text, "场内"):
+		return StatusSuspended
+	}
+	return StatusOpen
+}
+
+//At 指定日期的交易状态，没有记录时为开放
+func (items AvailabilityList) At(date time.Time) Availability {
+	k := sort.Search(len(items), func(i int) bool {
+		return DiffDays(items[i].Date, date) > 0
+	})
+	if k == 0 {
+		return Availability{Date: date}
+	}
+	return items[k-1]
+}
+
+//WithLimit 为没有限额的限大额记录设置每日申购上限。天天基金的净值表只有状态没有限额
+func (items AvailabilityList) WithLimit(limit float32) AvailabilityList {
+	list := make(AvailabilityList, len(items))
+	for k, a := range items {
+		if a.Subscribe == StatusLimited && a.Limit == 0 {
+			a.Limit = limit
+		}
+		list[k] = a
+	}
+	return list
+}
+
+//compact 按日期排序并合并状态相同的相邻记录
+func (items AvailabilityList) compact() AvailabilityList {
+	items = append(AvailabilityList(nil), items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date.Before(items[j].Date)
+	})
+	var list AvailabilityList
+	for _, a := range items {
+		if n := len(list); n > 0 {
+			last := list[n-1]
+			if last.Subscribe == a.Subscribe && last.Redeem == a.Redeem && last.Limit == a.Limit {
+				continue
+			}
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+//ImportAvailabilityCSV 读取交易状态，列依次为 日期,申购状态,赎回状态,每日限额。第一行为表头
+func ImportAvailabilityCSV(r io.Reader) (AvailabilityList, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var items AvailabilityList
+	for line, row := range rows {
+		if line == 0 || strings.Join(row, "") == "" {
+			continue
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("第%d行: 列数不足", line+1)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", line+1, err)
+		}
+		a := Availability{
+			Date:      date,
+			Subscribe: ParseStatus(row[1]),
+			Redeem:    ParseStatus(row[2]),
+		}
+		if len(row) > 3 {
+			a.Limit = parseNumber(row[3])
+		}
+		items = append(items, a)
+	}
+	return items.compact(), nil
+}
+
+//SetAvailability 设置基金的交易状态，暂停申购或赎回时拒绝交易，限大额时超出部分不买入
+func (ctx *Engine) SetAvailability(items AvailabilityList) {
+	ctx.avail = items.compact()
+}
+
+//subscribable 按交易状态调整申购金额，未买入部分记录为拒绝
+func (ctx *Engine) subscribable(amount float32, nw NetWorth, transType TransType) float32 {
+	a := ctx.avail.At(nw.Date)
+	switch {
+	case a.Subscribe == StatusSuspended:
+		ctx.reject(nw, transType, amount, 0, "暂停申购")
+		return 0
+	case a.Subscribe == StatusLimited && a.Limit > 0:
+		left := a.Limit - ctx.subscribed(nw.Date)
+		if left < 0 {
+			left = 0
+		}
+		if amount > left {
+			ctx.reject(nw, transType, amount-left, 0, fmt.Sprintf("限大额，每日申购上限%.2f", a.Limit))
+			return left
+		}
+	}
+	return amount
+}
+
+//subscribed 当日已申购的金额
+func (ctx *Engine) subscribed(date time.Time) float32 {
+	var amount float32
+	for i := len(ctx.trans) - 1; i >= 0 && DiffDays(ctx.trans[i].Date, date) == 0; i-- {
+		if t := ctx.trans[i]; t.TransType == TransFixed || t.TransType == TransAppend || t.TransType == TransContribute {
+			amount += t.Amount
+		}
+	}
+	return amount
+}
+
+//redirect 将项目未买入的金额转投到备选项目，记为备选项目的追加买入，不影响其定投周期
+func (e *PackEngine) redirect(item *PackItem, amount float32) {
+	if item.Fallback == "" || amount < 1 {
+		return
+	}
+	for k := range e.items {
+		to := &e.items[k]
+		if to == item || to.strategy.Code != item.Fallback {
+			continue
+		}
+		i, nw := to.nws.Today(e.now)
+		if i < 0 {
+			return
+		}
+		trans := to.append(amount, nw)
+		if trans == nil {
+			return
+		}
+		e.balance -= trans.Amount
+		to.refresh(nw)
+		e.record(to, trans)
+		return
+	}
+}
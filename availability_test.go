@@ -0,0 +1,86 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatus(t *testing.T) {
+	assert.Equal(t, StatusOpen, ParseStatus("开放申购"))
+	assert.Equal(t, StatusLimited, ParseStatus("限大额"))
+	assert.Equal(t, StatusLimited, ParseStatus("暂停大额申购"))
+	assert.Equal(t, StatusSuspended, ParseStatus("暂停申购"))
+	assert.Equal(t, StatusSuspended, ParseStatus("封闭期"))
+}
+
+func TestImportAvailabilityCSV(t *testing.T) {
+	items, err := ImportAvailabilityCSV(strings.NewReader(`日期,申购状态,赎回状态,限额
+2020-03-01,限大额,开放赎回,"1,000"
+2020-01-01,开放申购,开放赎回,
+2020-03-02,限大额,开放赎回,1000
+2020-06-01,暂停申购,暂停赎回,
+`))
+	assert.NoError(t, err)
+	assert.Len(t, items, 3)
+	assert.Equal(t, StatusOpen, items.At(ParseDate("2019-12-01")).Subscribe)
+	assert.Equal(t, float32(1000), items.At(ParseDate("2020-05-31")).Limit)
+	assert.Equal(t, StatusSuspended, items.At(ParseDate("2020-06-01")).Redeem)
+	assert.Equal(t, float32(500), AvailabilityList{{Subscribe: StatusLimited}}.WithLimit(500)[0].Limit)
+}
+
+func TestEngineAvailability(t *testing.T) {
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	e := NewEngine(s, waveNws("2020-01-01", 1000))
+	e.SetAvailability(AvailabilityList{
+		{Date: ParseDate("2020-01-01"), Subscribe: StatusLimited, Limit: 500},
+		{Date: ParseDate("2021-01-01"), Subscribe: StatusSuspended, Redeem: StatusSuspended},
+		{Date: ParseDate("2022-01-01")},
+	})
+	result := e.Run()
+	assert.NotEmpty(t, result.Rejects)
+	for _, tr := range result.TransList {
+		if tr.Date.Year() == 2020 && (tr.TransType == TransFixed || tr.TransType == TransAppend) {
+			assert.LessOrEqual(t, tr.Amount, float32(500))
+		}
+		if tr.Date.Year() == 2021 {
+			assert.Equal(t, TransDividends, tr.TransType)
+		}
+	}
+}
+
+func TestPackEngineFallback(t *testing.T) {
+	limited := NewEngine(waveStrategy("2020-01-01", "2021-12-31"), waveNws("2020-01-01", 800))
+	limited.SetAvailability(AvailabilityList{{Date: ParseDate("2020-01-01"), Subscribe: StatusSuspended}})
+	s := waveStrategy("2020-01-01", "2021-12-31")
+	s.Code = "fallback"
+	items := PackItemList{
+		{Engine: limited, TOF: Radical, Precent: 50, Fallback: "fallback"},
+		{Engine: NewEngine(s, flatNws("2020-01-01", 800, 1)), TOF: Conservative, Precent: 50},
+	}
+	e := NewPackEngine(items, ParseDate("2020-01-01"), 2000)
+	e.SetEndDate(ParseDate("2021-12-31"))
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	result := e.Run()
+	assert.Empty(t, result.Items[0].TransList)
+	assert.NotEmpty(t, result.Items[0].Rejects)
+	assert.NotEmpty(t, result.Items[1].TransList)
+
+	//转投记为追加买入，备选项目当天仍按自己的周期定投
+	var fixed, appended int
+	for _, tr := range result.Items[1].TransList {
+		if tr.Date.Equal(ParseDate("2020-01-15")) {
+			switch tr.TransType {
+			case TransFixed:
+				fixed++
+			case TransAppend:
+				appended++
+			}
+		}
+	}
+	assert.Equal(t, 1, fixed)
+	assert.Equal(t, 1, appended)
+}
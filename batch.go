@@ -0,0 +1,263 @@
+package backtesting
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	//Loader 净值加载
+	Loader func(code string) (NetWorthList, error)
+	//Batch 批量回测
+	Batch struct {
+		Strategy Strategy //回测策略，基金代码由列表填充
+		Workers  int      //并发数，默认4
+		Store    string   //结果文件，每行保存一只基金的JSON结果，重新运行时跳过已完成的基金
+		Loader   Loader   //净值加载，默认从天天基金获取
+		RiskFree float64  //无风险年化收益率(%)，用于计算夏普比率
+	}
+	//BatchResult 单只基金的回测指标
+	BatchResult struct {
+		Code        string
+		Name        string
+		Invest      float32
+		Value       float32
+		Profit      float32
+		Rop         float32
+		XIRR        float64 //策略的年化收益率(%)
+		CAGR        float64 //基金区间年化收益率(%)
+		MaxDrawdown float64 //基金区间最大回撤(%)
+		Volatility  float64 //基金区间年化波动率(%)
+		Sharpe      float64 //基金区间夏普比率
+		Trades      int     //按策略信号买入及卖出的次数，不含分红再投资
+		Error       string  //失败原因
+	}
+	//BatchResults 批量回测结果
+	BatchResults []BatchResult
+)
+
+//TianTianLoader 从天天基金获取净值，包含开始日期前一年的数据用于计算周期涨跌幅
+func TianTianLoader(start, end time.Time) Loader {
+	return func(code string) (nws NetWorthList, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("获取%s净值失败: %v", code, r)
+			}
+		}()
+		return TianTian.GetHistories(code, DateToString(start.AddDate(-1, 0, 0)), DateToString(end)), nil
+	}
+}
+
+//CachedLoader 将净值缓存到目录，已缓存的直接读取文件
+func CachedLoader(dir string, loader Loader) Loader {
+	return func(code string) (NetWorthList, error) {
+		file := filepath.Join(dir, code+".json")
+		var nws NetWorthList
+		if bts, err := ioutil.ReadFile(file); err == nil {
+			if err := json.Unmarshal(bts, &nws); err == nil {
+				return nws, nil
+			}
+		}
+		nws, err := loader(code)
+		if err != nil {
+			return nil, err
+		}
+		bts, err := json.Marshal(nws)
+		if err != nil {
+			return nil, err
+		}
+		return nws, ioutil.WriteFile(file, bts, 0644)
+	}
+}
+
+//Run 并发回测基金列表。结果按完成顺序追加到结果文件，中断后重新运行会跳过已成功的基金
+func (b Batch) Run(funds []Basic) (BatchResults, error) {
+	done, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	var store *os.File
+	if b.Store != "" {
+		store, err = os.OpenFile(b.Store, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+	}
+	workers := b.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	if b.Loader == nil {
+		end := b.Strategy.EndDate
+		if end.IsZero() {
+			end = time.Now()
+		}
+		b.Loader = TianTianLoader(b.Strategy.StartDate, end)
+	}
+
+	results := make(BatchResults, 0, len(funds))
+	var todo []Basic
+	for _, fund := range funds {
+		if result, ok := done[fund.Code]; ok {
+			results = append(results, result)
+			continue
+		}
+		todo = append(todo, fund)
+	}
+	var mu sync.Mutex
+	var werr error
+	parallel(len(todo), workers, func(i int) {
+		result := b.run(todo[i])
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+		if store != nil && werr == nil {
+			werr = writeResult(store, result)
+		}
+	})
+	return results, werr
+}
+
+//run 回测单只基金
+func (b Batch) run(fund Basic) (result BatchResult) {
+	result = BatchResult{Code: fund.Code, Name: fund.Name}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprint(r)
+		}
+	}()
+	nws, err := b.Loader(fund.Code)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	s := b.Strategy
+	s.Code = fund.Code
+	r := NewEngine(s, nws).Run()
+	end := s.EndDate
+	if end.IsZero() {
+		end = r.Date
+	}
+	window := nws.Window(s.StartDate, end)
+	result.Invest = r.Invest
+	result.Value = r.Value
+	result.Profit = r.Profit
+	result.Rop = r.Rop
+	result.XIRR = r.XIRR()
+	result.CAGR = window.CAGR()
+	result.MaxDrawdown = window.MaxDrawdown()
+	result.Volatility = window.Volatility()
+	result.Sharpe = window.Sharpe(b.RiskFree)
+	for _, t := range r.TransList {
+		switch t.TransType {
+		case TransFixed, TransAppend, TransSell:
+			result.Trades++
+		}
+	}
+	return result
+}
+
+//load 读取已成功的结果，失败的结果重新运行时再次回测
+func (b Batch) load() (map[string]BatchResult, error) {
+	done := map[string]BatchResult{}
+	if b.Store == "" {
+		return done, nil
+	}
+	file, err := os.Open(b.Store)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var result BatchResult
+		//中断时可能写入了不完整的行，忽略即可
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil || result.Error != "" {
+			continue
+		}
+		done[result.Code] = result
+	}
+	return done, scanner.Err()
+}
+
+func writeResult(w io.Writer, result BatchResult) error {
+	//JSON不支持NaN，无法计算的指标保存为0
+	for _, v := range []*float64{&result.XIRR, &result.CAGR, &result.MaxDrawdown, &result.Volatility, &result.Sharpe} {
+		if math.IsNaN(*v) || math.IsInf(*v, 0) {
+			*v = 0
+		}
+	}
+	bts, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bts, '\n'))
+	return err
+}
+
+//Sort 按指标排序，field为BatchResult的字段名，失败的结果排在最后
+func (items BatchResults) Sort(field string, desc bool) {
+	key := func(r BatchResult) float64 {
+		switch field {
+		case "Invest":
+			return float64(r.Invest)
+		case "Value":
+			return float64(r.Value)
+		case "Profit":
+			return float64(r.Profit)
+		case "Rop":
+			return float64(r.Rop)
+		case "XIRR":
+			return r.XIRR
+		case "CAGR":
+			return r.CAGR
+		case "MaxDrawdown":
+			return r.MaxDrawdown
+		case "Volatility":
+			return r.Volatility
+		case "Sharpe":
+			return r.Sharpe
+		case "Trades":
+			return float64(r.Trades)
+		}
+		return 0
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		ka, kb := key(a), key(b)
+		if math.IsNaN(ka) || math.IsNaN(kb) {
+			return !math.IsNaN(ka)
+		}
+		if desc {
+			return ka > kb
+		}
+		return ka < kb
+	})
+}
+
+//Table 输出指标表格
+func (items BatchResults) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "代码\t名称\t本金\t价值\t利润\t收益率\t年化\t基金年化\t最大回撤\t波动率\t夏普\t交易次数\t错误")
+	for _, r := range items {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%d\t%s\n",
+			r.Code, r.Name, r.Invest, r.Value, r.Profit, r.Rop, r.XIRR, r.CAGR, r.MaxDrawdown, r.Volatility, r.Sharpe, r.Trades, r.Error)
+	}
+	return tw.Flush()
+}
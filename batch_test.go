@@ -0,0 +1,100 @@
+package backtesting
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	funds := []Basic{{Code: "000001", Name: "波动"}, {Code: "000002", Name: "平稳"}, {Code: "000003", Name: "失败"}}
+	var calls int32
+	loader := func(code string) (NetWorthList, error) {
+		atomic.AddInt32(&calls, 1)
+		switch code {
+		case "000001":
+			return waveNws("2020-01-01", 1000), nil
+		case "000002":
+			return flatNws("2020-01-01", 1000, 1), nil
+		}
+		return nil, errors.New("没有数据")
+	}
+	b := Batch{
+		Strategy: waveStrategy("2020-01-01", "2022-06-30"),
+		Workers:  2,
+		Store:    filepath.Join(dir, "results.jsonl"),
+		Loader:   loader,
+	}
+	results, err := b.Run(funds)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, int32(3), calls)
+
+	results.Sort("Profit", true)
+	assert.Equal(t, "000003", results[2].Code)
+	assert.Equal(t, "没有数据", results[2].Error)
+	assert.True(t, results[0].Profit >= results[1].Profit)
+
+	//重新运行时只回测失败的基金
+	results, err = b.Run(funds)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, int32(4), calls)
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.Table(&buf))
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"))
+}
+
+func TestBatchTrades(t *testing.T) {
+	//每季度分红一次，分红再投资不计为交易
+	nws := waveNws("2020-01-01", 1000)
+	for i := 60; i < len(nws); i += 90 {
+		nws[i].Dividends = 0.01
+	}
+	s := waveStrategy("2020-01-01", "2022-06-30")
+	b := Batch{Strategy: s, Loader: func(code string) (NetWorthList, error) {
+		return nws, nil
+	}}
+	result := b.run(Basic{Code: "000001"})
+	r := NewEngine(s, nws).Run()
+	var trades, dividends int
+	for _, t := range r.TransList {
+		switch t.TransType {
+		case TransFixed, TransAppend, TransSell:
+			trades++
+		case TransDividends:
+			dividends++
+		}
+	}
+	assert.NotZero(t, dividends)
+	assert.Equal(t, trades, result.Trades)
+	assert.Equal(t, len(r.TransList)-dividends, result.Trades)
+}
+
+func TestCachedLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	calls := 0
+	loader := CachedLoader(dir, func(code string) (NetWorthList, error) {
+		calls++
+		return flatNws("2020-01-01", 10, 1), nil
+	})
+	for i := 0; i < 2; i++ {
+		nws, err := loader("000001")
+		assert.NoError(t, err)
+		assert.Len(t, nws, 10)
+	}
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,141 @@
+package backtesting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+//benchYears 基准测试的历史长度，每日耗时(ns/day)基本不变说明随历史长度线性增长
+var benchYears = []int{5, 10, 20}
+
+func benchNws(years int, seed int64) NetWorthList {
+	return Synthetic{Start: ParseDate("2000-01-03"), Days: years * tradingDays, Seed: seed}.Generate(MeanRevert{Mean: 1.3, Speed: 1, Vol: 20})
+}
+
+func benchStrategy(code string, nws NetWorthList) Strategy {
+	return Strategy{
+		Code:        code,
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		SellPoint:   20,
+		StartDate:   nws[tradingDays].Date,
+		EndDate:     nws[len(nws)-1].Date,
+		TransRate:   0.15,
+		SellRate:    0.5,
+		CycleType:   CycleMonth,
+		CycleValue:  10,
+		VolaDays:    120,
+		FixedMethod: FloatInvest,
+	}
+}
+
+//benchRun 按历史长度运行子基准测试，fn返回每次运行计算的天数
+func benchRun(b *testing.B, fn func(years int) func() int) {
+	for _, years := range benchYears {
+		b.Run(fmt.Sprintf("%dy", years), func(b *testing.B) {
+			run := fn(years)
+			b.ResetTimer()
+			start := time.Now()
+			days := 0
+			for i := 0; i < b.N; i++ {
+				days += run()
+			}
+			b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(days), "ns/day")
+		})
+	}
+}
+
+func BenchmarkToday(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		nws := benchNws(years, 1)
+		return func() int {
+			for _, nw := range nws {
+				nws.Today(nw.Date)
+			}
+			return len(nws)
+		}
+	})
+}
+
+func BenchmarkInitVolaRoc(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		nws := benchNws(years, 1)
+		return func() int {
+			nws.InitVolaRoc(250)
+			return len(nws)
+		}
+	})
+}
+
+func BenchmarkEngine(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		nws := benchNws(years, 1)
+		s := benchStrategy("bench", nws)
+		return func() int {
+			NewEngine(s, nws).Run()
+			return len(nws)
+		}
+	})
+}
+
+func BenchmarkPackEngine(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		a, c, m := benchNws(years, 1), benchNws(years, 2), benchNws(years, 3)
+		s := benchStrategy("a", a)
+		return func() int {
+			items := PackItemList{
+				{Engine: NewEngine(s, a), TOF: Radical, Precent: 40},
+				{Engine: NewEngine(benchStrategy("b", c), c), TOF: Radical, Precent: 30},
+				{Engine: NewEngine(benchStrategy("c", m), m), TOF: Conservative, Precent: 30},
+			}
+			e := NewPackEngine(items, s.StartDate, 3000)
+			e.SetEndDate(s.EndDate)
+			e.SetLogger(log.New(ioutil.Discard, "", 0))
+			e.Run()
+			return len(a)
+		}
+	})
+}
+
+func BenchmarkBatch(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		funds := make([]Basic, 8)
+		data := map[string]NetWorthList{}
+		for k := range funds {
+			funds[k].Code = fmt.Sprintf("%06d", k+1)
+			data[funds[k].Code] = benchNws(years, int64(k+1))
+		}
+		nws := data[funds[0].Code]
+		batch := Batch{
+			Strategy: benchStrategy("", nws),
+			Workers:  1,
+			Loader: func(code string) (NetWorthList, error) {
+				return data[code], nil
+			},
+		}
+		return func() int {
+			batch.Run(funds)
+			return len(nws) * len(funds)
+		}
+	})
+}
+
+func BenchmarkSolveGoal(b *testing.B) {
+	benchRun(b, func(years int) func() int {
+		paths := []NetWorthList{benchNws(years, 1), benchNws(years, 2), benchNws(years, 3), benchNws(years, 4)}
+		s := benchStrategy("goal", paths[0])
+		goal := Goal{Target: float32(years) * 15000, Date: s.EndDate, Confidence: 0.5, MaxAmount: 5000}
+		return func() int {
+			var runs int
+			SolveGoal(goal, len(paths), func(amount float32, path int) float32 {
+				runs++
+				return EngineRunner(s, paths)(amount, path)
+			})
+			return runs * len(paths[0])
+		}
+	})
+}
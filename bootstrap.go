@@ -0,0 +1,65 @@
+package backtesting
+
+import (
+	"math/rand"
+	"time"
+)
+
+//Bootstrap 按块重采样历史涨跌幅生成模拟净值路径。
+//每条路径沿用原始日期及首日净值，block为每次抽取的连续交易日数，用于保留波动的聚集性
+func Bootstrap(nws NetWorthList, paths, block int, seed int64) []NetWorthList {
+	if len(nws) < 2 || paths <= 0 {
+		return nil
+	}
+	if block <= 0 {
+		block = 20
+	}
+	if block > len(nws)-1 {
+		block = len(nws) - 1
+	}
+	r := rand.New(rand.NewSource(seed))
+	items := make([]NetWorthList, paths)
+	for p := range items {
+		path := make(NetWorthList, len(nws))
+		path[0] = NetWorth{Date: nws[0].Date, NAV: nws[0].NAV, CNAV: nws[0].NAV}
+		nav := float64(nws[0].NAV)
+		for i := 1; i < len(nws); {
+			start := 1 + r.Intn(len(nws)-block)
+			for j := start; j < start+block && i < len(nws); j++ {
+				roc := nws[j].ROC
+				nav *= 1 + float64(roc)/100
+				path[i] = NetWorth{Date: nws[i].Date, NAV: float32(nav), CNAV: float32(nav), ROC: roc}
+				i++
+			}
+		}
+		items[p] = path
+	}
+	return items
+}
+
+//HistoricalPaths 按滚动窗口截取历史净值作为模拟路径。
+//每条路径长度为years年，窗口间隔step个月，日期平移到start开始，平移天数取整周以保持星期不变
+func HistoricalPaths(nws NetWorthList, start time.Time, years, step int) []NetWorthList {
+	if len(nws) == 0 || years <= 0 {
+		return nil
+	}
+	if step <= 0 {
+		step = 1
+	}
+	var items []NetWorthList
+	last := nws[len(nws)-1].Date
+	for from := nws[0].Date; DiffDays(from.AddDate(years, 0, 0), last) <= 0; from = from.AddDate(0, step, 0) {
+		shift := DiffDays(start, from) / 7 * 7
+		to := from.AddDate(years, 0, 0)
+		var path NetWorthList
+		for _, nw := range nws {
+			if DiffDays(nw.Date, from) < 0 || DiffDays(nw.Date, to) >= 0 {
+				continue
+			}
+			nw.Date = nw.Date.AddDate(0, 0, shift)
+			path = append(path, nw)
+		}
+		items = append(items, path)
+	}
+	return items
+}
@@ -0,0 +1,198 @@
+package backtesting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//Roll 投资日为非交易日时的处理方式
+type Roll int
+
+const (
+	//RollForward 顺延到下一个交易日
+	RollForward Roll = 0
+	//RollBackward 提前到上一个交易日
+	RollBackward Roll = 1
+	//RollSkip 跳过当期
+	RollSkip Roll = 2
+)
+
+//Shanghai 交易所所在时区，1991年后没有夏令时
+var Shanghai = time.FixedZone("CST", 8*3600)
+
+var (
+	//calendarStart 交易日历的起始日期，范围外只排除周末
+	calendarStart = ParseDate("2005-01-01")
+	//calendarEnd 交易日历的截止日期，之后的节假日需通过AddClosures补充
+	calendarEnd = ParseDate("2026-12-31")
+	//closures 上交所、深交所工作日休市区间，含首尾日期
+	closures = [][2]string{
+		{"2005-01-03", "2005-01-03"}, {"2005-02-07", "2005-02-15"}, {"2005-05-02", "2005-05-06"}, {"2005-10-03", "2005-10-07"},
+		{"2006-01-02", "2006-01-03"}, {"2006-01-26", "2006-02-03"}, {"2006-05-01", "2006-05-05"}, {"2006-10-02", "2006-10-06"},
+		{"2007-01-01", "2007-01-03"}, {"2007-02-16", "2007-02-23"}, {"2007-05-01", "2007-05-07"}, {"2007-10-01", "2007-10-05"}, {"2007-12-31", "2007-12-31"},
+		{"2008-01-01", "2008-01-01"}, {"2008-02-06", "2008-02-12"}, {"2008-04-04", "2008-04-04"}, {"2008-05-01", "2008-05-02"}, {"2008-06-09", "2008-06-09"}, {"2008-09-15", "2008-09-15"}, {"2008-09-29", "2008-10-03"},
+		{"2009-01-01", "2009-01-02"}, {"2009-01-26", "2009-01-30"}, {"2009-04-06", "2009-04-06"}, {"2009-05-01", "2009-05-01"}, {"2009-05-28", "2009-05-29"}, {"2009-10-01", "2009-10-08"},
+		{"2010-01-01", "2010-01-01"}, {"2010-02-15", "2010-02-19"}, {"2010-04-05", "2010-04-05"}, {"2010-05-03", "2010-05-03"}, {"2010-06-14", "2010-06-16"}, {"2010-09-22", "2010-09-24"}, {"2010-10-01", "2010-10-07"},
+		{"2011-01-03", "2011-01-03"}, {"2011-02-02", "2011-02-08"}, {"2011-04-04", "2011-04-05"}, {"2011-05-02", "2011-05-02"}, {"2011-06-06", "2011-06-06"}, {"2011-09-12", "2011-09-12"}, {"2011-10-03", "2011-10-07"},
+		{"2012-01-02", "2012-01-03"}, {"2012-01-23", "2012-01-27"}, {"2012-04-02", "2012-04-04"}, {"2012-04-30", "2012-05-01"}, {"2012-06-22", "2012-06-22"}, {"2012-10-01", "2012-10-05"},
+		{"2013-01-01", "2013-01-03"}, {"2013-02-11", "2013-02-15"}, {"2013-04-04", "2013-04-05"}, {"2013-04-29", "2013-05-01"}, {"2013-06-10", "2013-06-12"}, {"2013-09-19", "2013-09-20"}, {"2013-10-01", "2013-10-07"},
+		{"2014-01-01", "2014-01-01"}, {"2014-01-31", "2014-02-06"}, {"2014-04-07", "2014-04-07"}, {"2014-05-01", "2014-05-02"}, {"2014-06-02", "2014-06-02"}, {"2014-09-08", "2014-09-08"}, {"2014-10-01", "2014-10-07"},
+		{"2015-01-01", "2015-01-02"}, {"2015-02-18", "2015-02-24"}, {"2015-04-06", "2015-04-06"}, {"2015-05-01", "2015-05-01"}, {"2015-06-22", "2015-06-22"}, {"2015-09-03", "2015-09-04"}, {"2015-10-01", "2015-10-07"},
+		{"2016-01-01", "2016-01-01"}, {"2016-02-08", "2016-02-12"}, {"2016-04-04", "2016-04-04"}, {"2016-05-02", "2016-05-02"}, {"2016-06-09", "2016-06-10"}, {"2016-09-15", "2016-09-16"}, {"2016-10-03", "2016-10-07"},
+		{"2017-01-02", "2017-01-02"}, {"2017-01-27", "2017-02-02"}, {"2017-04-03", "2017-04-04"}, {"2017-05-01", "2017-05-01"}, {"2017-05-29", "2017-05-30"}, {"2017-10-02", "2017-10-06"},
+		{"2018-01-01", "2018-01-01"}, {"2018-02-15", "2018-02-21"}, {"2018-04-05", "2018-04-06"}, {"2018-04-30", "2018-05-01"}, {"2018-06-18", "2018-06-18"}, {"2018-09-24", "2018-09-24"}, {"2018-10-01", "2018-10-05"}, {"2018-12-31", "2018-12-31"},
+		{"2019-01-01", "2019-01-01"}, {"2019-02-04", "2019-02-08"}, {"2019-04-05", "2019-04-05"}, {"2019-05-01", "2019-05-03"}, {"2019-06-07", "2019-06-07"}, {"2019-09-13", "2019-09-13"}, {"2019-10-01", "2019-10-07"},
+		{"2020-01-01", "2020-01-01"}, {"2020-01-24", "2020-01-31"}, {"2020-04-06", "2020-04-06"}, {"2020-05-01", "2020-05-05"}, {"2020-06-25", "2020-06-26"}, {"2020-10-01", "2020-10-08"},
+		{"2021-01-01", "2021-01-01"}, {"2021-02-11", "2021-02-17"}, {"2021-04-05", "2021-04-05"}, {"2021-05-03", "2021-05-05"}, {"2021-06-14", "2021-06-14"}, {"2021-09-20", "2021-09-21"}, {"2021-10-01", "2021-10-07"},
+		{"2022-01-03", "2022-01-03"}, {"2022-01-31", "2022-02-04"}, {"2022-04-04", "2022-04-05"}, {"2022-05-02", "2022-05-04"}, {"2022-06-03", "2022-06-03"}, {"2022-09-12", "2022-09-12"}, {"2022-10-03", "2022-10-07"},
+		{"2023-01-02", "2023-01-02"}, {"2023-01-23", "2023-01-27"}, {"2023-04-05", "2023-04-05"}, {"2023-05-01", "2023-05-03"}, {"2023-06-22", "2023-06-23"}, {"2023-09-29", "2023-09-29"}, {"2023-10-02", "2023-10-06"},
+		{"2024-01-01", "2024-01-01"}, {"2024-02-09", "2024-02-16"}, {"2024-04-04", "2024-04-05"}, {"2024-05-01", "2024-05-03"}, {"2024-06-10", "2024-06-10"}, {"2024-09-16", "2024-09-17"}, {"2024-10-01", "2024-10-07"},
+		{"2025-01-01", "2025-01-01"}, {"2025-01-28", "2025-02-04"}, {"2025-04-04", "2025-04-04"}, {"2025-05-01", "2025-05-05"}, {"2025-06-02", "2025-06-02"}, {"2025-10-01", "2025-10-08"},
+		{"2026-01-01", "2026-01-02"}, {"2026-02-16", "2026-02-23"}, {"2026-04-06", "2026-04-06"}, {"2026-05-01", "2026-05-05"}, {"2026-06-19", "2026-06-19"}, {"2026-09-25", "2026-09-25"}, {"2026-10-01", "2026-10-07"},
+	}
+	//holidays 工作日休市的日期
+	holidays = func() map[int64]bool {
+		m := map[int64]bool{}
+		for _, c := range closures {
+			for d, end := ParseDate(c[0]), ParseDate(c[1]); !d.After(end); d = d.AddDate(0, 0, 1) {
+				m[d.Unix()/86400] = true
+			}
+		}
+		return m
+	}()
+)
+
+//Closure 工作日休市区间，含首尾日期
+type Closure struct {
+	From time.Time
+	To   time.Time
+}
+
+//AddClosures 补充休市区间，交易日历的范围扩展到end及各区间所在的年份。
+//修改的是全局日历，应在回测开始前调用
+func AddClosures(end time.Time, list ...Closure) {
+	if end = civil(end); end.After(calendarEnd) {
+		calendarEnd = end
+	}
+	for _, c := range list {
+		from, to := civil(c.From), civil(c.To)
+		if first := time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC); first.Before(calendarStart) {
+			calendarStart = first
+		}
+		if last := time.Date(to.Year(), 12, 31, 0, 0, 0, 0, time.UTC); last.After(calendarEnd) {
+			calendarEnd = last
+		}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			holidays[d.Unix()/86400] = true
+		}
+	}
+}
+
+//ImportClosuresCSV 从CSV读取休市区间，首行为表头，列依次为开始日期、结束日期，结束日期为空时只休市一天
+func ImportClosuresCSV(r io.Reader) ([]Closure, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var list []Closure
+	for line, row := range rows {
+		if line == 0 || strings.Join(row, "") == "" {
+			continue
+		}
+		from, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", line+1, err)
+		}
+		c := Closure{From: from, To: from}
+		if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+			if c.To, err = time.Parse("2006-01-02", strings.TrimSpace(row[1])); err != nil {
+				return nil, fmt.Errorf("第%d行: %v", line+1, err)
+			}
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+//civil 上海时区的日期，以UTC零点表示，与ParseDate一致
+func civil(t time.Time) time.Time {
+	y, m, d := t.In(Shanghai).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+//IsTradingDay 是否为交易日。交易日历范围外只排除周末，范围见calendarStart、calendarEnd，可通过AddClosures扩展
+func IsTradingDay(date time.Time) bool {
+	date = civil(date)
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	if date.Before(calendarStart) || date.After(calendarEnd) {
+		return true
+	}
+	return !holidays[date.Unix()/86400]
+}
+
+//NextTradingDay 之后的第一个交易日
+func NextTradingDay(date time.Time) time.Time {
+	return AddTradingDays(date, 1)
+}
+
+//PrevTradingDay 之前的最后一个交易日
+func PrevTradingDay(date time.Time) time.Time {
+	return AddTradingDays(date, -1)
+}
+
+//AddTradingDays 加上n个交易日，n为负数时向前。n为0时返回当日，当日不是交易日时顺延
+func AddTradingDays(date time.Time, n int) time.Time {
+	date = civil(date)
+	if n == 0 {
+		return RollForward.Adjust(date)
+	}
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		date = date.AddDate(0, 0, step)
+		if IsTradingDay(date) {
+			n--
+		}
+	}
+	return date
+}
+
+//TradingDaysBetween 区间(from, to]内的交易日数，to早于from时为负数
+func TradingDaysBetween(from, to time.Time) int {
+	from, to = civil(from), civil(to)
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	var days int
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if IsTradingDay(d) {
+			days++
+		}
+	}
+	return days * sign
+}
+
+//Adjust 将日期调整为交易日，跳过当期时返回零值
+func (r Roll) Adjust(date time.Time) time.Time {
+	date = civil(date)
+	if IsTradingDay(date) {
+		return date
+	}
+	switch r {
+	case RollBackward:
+		return PrevTradingDay(date)
+	case RollSkip:
+		return time.Time{}
+	}
+	return NextTradingDay(date)
+}
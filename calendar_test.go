@@ -0,0 +1,86 @@
+package backtesting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradingCalendar(t *testing.T) {
+	//春节休市，调休的周日同样休市
+	assert.False(t, IsTradingDay(ParseDate("2021-02-11")))
+	assert.False(t, IsTradingDay(ParseDate("2021-02-07")))
+	assert.False(t, IsTradingDay(ParseDate("2021-02-13")))
+	assert.True(t, IsTradingDay(ParseDate("2021-02-18")))
+	//内置日历范围外只排除周末
+	assert.True(t, IsTradingDay(ParseDate("2030-01-01")))
+	assert.False(t, IsTradingDay(ParseDate("2026-02-17")))
+	assert.False(t, IsTradingDay(ParseDate("2026-10-07")))
+	assert.True(t, IsTradingDay(ParseDate("2026-10-09")))
+
+	assert.Equal(t, "2021-10-08", DateToString(NextTradingDay(ParseDate("2021-09-30"))))
+	assert.Equal(t, "2021-09-30", DateToString(PrevTradingDay(ParseDate("2021-10-08"))))
+	assert.Equal(t, "2021-02-18", DateToString(AddTradingDays(ParseDate("2021-02-10"), 1)))
+	assert.Equal(t, "2021-02-18", DateToString(AddTradingDays(ParseDate("2021-02-13"), 0)))
+	assert.Equal(t, "2021-02-09", DateToString(AddTradingDays(ParseDate("2021-02-18"), -2)))
+	assert.Equal(t, 1, TradingDaysBetween(ParseDate("2021-09-30"), ParseDate("2021-10-08")))
+	assert.Equal(t, -1, TradingDaysBetween(ParseDate("2021-10-08"), ParseDate("2021-09-30")))
+
+	holiday := ParseDate("2021-10-01")
+	assert.Equal(t, "2021-10-08", DateToString(RollForward.Adjust(holiday)))
+	assert.Equal(t, "2021-09-30", DateToString(RollBackward.Adjust(holiday)))
+	assert.True(t, RollSkip.Adjust(holiday).IsZero())
+	assert.Equal(t, "2021-09-30", DateToString(RollSkip.Adjust(ParseDate("2021-09-30"))))
+
+	//上海时区凌晨已是第二天
+	assert.Equal(t, 1, DiffDays(time.Date(2021, 1, 2, 1, 0, 0, 0, Shanghai), ParseDate("2021-01-01")))
+}
+
+func TestAddClosures(t *testing.T) {
+	start, end := calendarStart, calendarEnd
+	defer func() {
+		calendarStart, calendarEnd = start, end
+	}()
+	list, err := ImportClosuresCSV(strings.NewReader(`开始日期,结束日期
+2030-01-01,
+2030-02-04,2030-02-08
+`))
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	_, err = ImportClosuresCSV(strings.NewReader("开始日期\n2030-13-01\n"))
+	assert.Error(t, err)
+
+	//2027年不在日历范围内，只排除周末
+	assert.True(t, IsTradingDay(ParseDate("2027-02-08")))
+	AddClosures(ParseDate("2027-12-31"), Closure{From: ParseDate("2027-02-08"), To: ParseDate("2027-02-12")})
+	AddClosures(time.Time{}, list...)
+	assert.False(t, IsTradingDay(ParseDate("2027-02-08")))
+	assert.True(t, IsTradingDay(ParseDate("2027-02-15")))
+	assert.False(t, IsTradingDay(ParseDate("2030-01-01")))
+	assert.Equal(t, "2030-02-11", DateToString(NextTradingDay(ParseDate("2030-02-01"))))
+	assert.Equal(t, "2030-12-31", DateToString(calendarEnd))
+	for _, d := range []string{"2027-02-08", "2027-02-09", "2027-02-10", "2027-02-11", "2027-02-12", "2030-01-01", "2030-02-04", "2030-02-05", "2030-02-06", "2030-02-07", "2030-02-08"} {
+		delete(holidays, ParseDate(d).Unix()/86400)
+	}
+}
+
+func TestStrategyRoll(t *testing.T) {
+	buys := func(roll Roll) []string {
+		s := Strategy{CycleType: CycleMonth, CycleValue: 15, Roll: roll}
+		var dates []string
+		var last time.Time
+		for now := ParseDate("2018-01-01"); now.Before(ParseDate("2018-04-01")); now = now.AddDate(0, 0, 1) {
+			if s.IsBuyDay(now, last) {
+				last = now
+				dates = append(dates, DateToString(now))
+			}
+		}
+		return dates
+	}
+	//2018-02-15为春节休市
+	assert.Equal(t, []string{"2018-01-15", "2018-02-22", "2018-03-15"}, buys(RollForward))
+	assert.Equal(t, []string{"2018-01-15", "2018-02-14", "2018-03-15"}, buys(RollBackward))
+	assert.Equal(t, []string{"2018-01-15", "2018-03-15"}, buys(RollSkip))
+}
@@ -0,0 +1,124 @@
+package backtesting
+
+import "fmt"
+
+//accrue 货币基金按每万份收益结转份额，没有万份收益时按七日年化收益率计算区间天数的收益
+func (ctx *Engine) accrue(nw NetWorth) {
+	if ctx.shares <= 0 || (nw.Yield == 0 && nw.Yield7 == 0) {
+		return
+	}
+	income := ctx.shares * nw.Yield / 10000
+	if nw.Yield == 0 {
+		days := 1
+		if !ctx.date.IsZero() {
+			days = DiffDays(nw.Date, ctx.date)
+		}
+		income = ctx.shares * nw.Yield7 / 100 / 365 * float32(days)
+	}
+	ctx.shares += income / nw.NAV
+}
+
+//liquid 可立即使用的资金，包括余额及现金类项目的价值
+func (e *PackEngine) liquid() float32 {
+	amount := e.balance
+	for _, item := range e.items {
+		if item.TOF == Cash {
+			amount += item.shares * item.cashNav()
+		}
+	}
+	return amount
+}
+
+//cashNav 现金类项目的最新净值，货币基金净值固定为1
+func (ctx *Engine) cashNav() float32 {
+	if ctx.nav > 0 {
+		return ctx.nav
+	}
+	return 1
+}
+
+//redeem 从现金类项目T+0赎回到余额，返回赎回到账的金额。当日可能没有净值，只更新价值不改变项目的日期，以免影响之后计提收益的天数
+func (e *PackEngine) redeem(amount float32) float32 {
+	var total float32
+	for k := range e.items {
+		item := &e.items[k]
+		if item.TOF != Cash || item.shares <= 0 || total >= amount {
+			continue
+		}
+		nw := NetWorth{Date: e.now, NAV: item.cashNav()}
+		shares := ParseFloat32(fmt.Sprintf("%.2f", (amount-total)/nw.NAV+0.005))
+		if shares > item.shares {
+			shares = item.shares
+		}
+		trans := item.sell(shares, nw)
+		if trans == nil {
+			continue
+		}
+		got := trans.Amount - trans.TransFee
+		e.balance += got
+		total += got
+		item.revalue()
+		e.record(item, trans)
+	}
+	return total
+}
+
+//sweep 将闲置余额按比例归集到当日有净值的现金类项目
+func (e *PackEngine) sweep() {
+	if e.balance < 1 {
+		return
+	}
+	var precent int
+	var items []*PackItem
+	var nws []NetWorth
+	for k := range e.items {
+		item := &e.items[k]
+		if item.TOF != Cash {
+			continue
+		}
+		i, nw := item.nws.Today(e.now)
+		if i < 0 {
+			continue
+		}
+		items = append(items, item)
+		nws = append(nws, nw)
+		precent += item.Precent
+	}
+	balance := e.balance
+	for k, item := range items {
+		amount := balance / float32(len(items))
+		if precent > 0 {
+			amount = balance * float32(item.Precent) / float32(precent)
+		}
+		amount = ParseFloat32(fmt.Sprintf("%.2f", amount))
+		if amount > e.balance {
+			amount = e.balance
+		}
+		trans := item.park(amount, nws[k])
+		if trans == nil {
+			continue
+		}
+		e.balance -= trans.Amount
+		item.refresh(nws[k])
+		e.record(item, trans)
+	}
+}
+
+//park 归集的闲置资金买入现金类项目，记为追加买入，不影响定投周期。
+//先使用之前赎回留在项目中的余额，其余计为投入
+func (ctx *Engine) park(amount float32, nw NetWorth) *Transaction {
+	if amount = ctx.purchasable(amount, nw, TransAppend); amount <= 0 {
+		return nil
+	}
+	used := ctx.balance
+	if used > amount {
+		used = amount
+	}
+	if used > 0 {
+		ctx.balance -= used
+		ctx.invest += amount - used
+	} else {
+		ctx.invest += amount
+	}
+	return ctx.buy(nw, amount, ctx.buyRate(), TransAppend)
+}
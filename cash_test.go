@@ -0,0 +1,105 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func moneyNws(start string, days int, yield, yield7 float32) NetWorthList {
+	nws := flatNws(start, days, 1)
+	for i := range nws {
+		nws[i].Yield = yield
+		nws[i].Yield7 = yield7
+	}
+	return nws
+}
+
+func TestEngineAccrue(t *testing.T) {
+	e := NewEngine(Strategy{}, nil)
+	e.shares = 10000
+	e.accrue(NetWorth{Date: ParseDate("2021-01-04"), NAV: 1, Yield: 0.8})
+	assert.InDelta(t, 10000.8, e.shares, 0.001)
+
+	//没有万份收益时按七日年化计算周末的收益
+	e.shares = 10000
+	e.date = ParseDate("2021-01-08")
+	e.accrue(NetWorth{Date: ParseDate("2021-01-11"), NAV: 1, Yield7: 3.65})
+	assert.InDelta(t, 10003, e.shares, 0.01)
+}
+
+func TestPackEngineCash(t *testing.T) {
+	start := ParseDate("2021-01-01")
+	radical := NewEngine(Strategy{
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		MinAmount:   100,
+		FixedMethod: FixedInvest,
+	}, flatNws("2021-01-01", 120, 1))
+	cash := NewEngine(Strategy{}, moneyNws("2021-01-01", 120, 1, 0))
+	items := PackItemList{
+		{Engine: radical, TOF: Radical, Precent: 50},
+		{Engine: cash, TOF: Cash, Precent: 50},
+	}
+	e := NewPackEngine(items, start, 10000)
+	e.SetEndDate(ParseDate("2021-03-31"))
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	result := e.Run()
+	//闲置余额全部归集到现金类
+	assert.True(t, result.Balance < 1)
+	assert.True(t, cash.shares > 0)
+	//激进型的买入从现金类赎回
+	assert.NotNil(t, radical.trans.LastBuy())
+	assert.NotNil(t, cash.trans.LastSell())
+	//现金类每日产生收益
+	assert.True(t, result.Value > result.Invest)
+	assert.InDelta(t, result.Value, radical.shares+cash.shares, 0.5)
+}
+
+func TestPackKeepBalanceProbe(t *testing.T) {
+	radical := NewEngine(Strategy{
+		BasicAmount: 1000,
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		MinAmount:   100,
+		FixedMethod: FixedInvest,
+	}, flatNws("2021-01-01", 60, 1))
+	stable := NewEngine(Strategy{}, flatNws("2021-01-01", 60, 1))
+	e := NewPackEngine(PackItemList{
+		{Engine: radical, TOF: Radical, Precent: 50},
+		{Engine: stable, TOF: Conservative, Precent: 50},
+	}, ParseDate("2021-01-01"), 10000)
+	e.now = ParseDate("2021-01-15")
+	_, nw := radical.nws.Today(e.now)
+	radical.begin(nw)
+	//试算预留金额不记录到激进型项目当日的决策
+	assert.NotZero(t, e.keepBalance())
+	assert.Empty(t, radical.decision.Steps)
+	assert.Empty(t, radical.decision.Checks)
+	assert.Nil(t, radical.decision.Cooldown)
+}
+
+func TestPackEngineSweep(t *testing.T) {
+	cash := NewEngine(Strategy{}, moneyNws("2021-01-01", 10, 1, 0))
+	e := NewPackEngine(PackItemList{{Engine: cash, TOF: Cash, Precent: 100}}, ParseDate("2021-01-01"), 10000)
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	e.AddBanlance(10000)
+	e.now = ParseDate("2021-01-04")
+	e.sweep()
+	assert.Equal(t, float32(10000), cash.shares)
+	assert.Equal(t, float32(10000), cash.invest)
+	//归集记为追加买入，不影响定投周期
+	assert.Nil(t, cash.lastTrans(TransFixed))
+	assert.NotNil(t, cash.lastTrans(TransAppend))
+
+	//赎回不改变项目的日期，再次归集先使用赎回的资金
+	date := cash.date
+	e.now = ParseDate("2021-01-05")
+	assert.Equal(t, float32(3000), e.redeem(3000))
+	assert.Equal(t, date, cash.date)
+	e.sweep()
+	assert.Equal(t, float32(10000), cash.invest)
+	assert.Equal(t, float32(0), cash.balance)
+}
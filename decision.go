@@ -0,0 +1,108 @@
+package backtesting
+
+import "time"
+
+type (
+	//Decision 交易决策记录，用于逐笔审计回测
+	Decision struct {
+		Date     time.Time
+		Signal   TransType //触发的信号
+		NAV      float32   //当日净值
+		ROC      float32   //当日涨跌幅
+		VolaRoc  float32   //周期内涨跌幅
+		Checks   []Check   //比较过的阈值
+		Steps    []Step    //金额或份额的计算步骤
+		Cooldown *Cooldown //冷却期状态
+		Skipped  string    //信号未执行的原因，成交时为空
+	}
+	//Check 阈值比较
+	Check struct {
+		Name   string
+		Value  float32
+		Op     string //比较符，如 ">" "<"
+		Limit  float32
+		Passed bool
+	}
+	//Step 计算步骤
+	Step struct {
+		Name  string
+		Value float32
+	}
+	//Cooldown 冷却期状态
+	Cooldown struct {
+		Last   time.Time //上次交易日期
+		Days   int       //距上次交易的交易日数
+		Min    int       //要求间隔的交易日数
+		Active bool      //是否处于冷却期
+	}
+)
+
+//begin 开始记录当日的决策
+func (ctx *Engine) begin(nw NetWorth) {
+	ctx.decision = &Decision{
+		Date:    nw.Date,
+		NAV:     nw.NAV,
+		ROC:     nw.ROC,
+		VolaRoc: nw.VolaRoc,
+	}
+}
+
+//check 记录阈值比较，返回比较结果
+func (ctx *Engine) check(name string, value float32, op string, limit float32) bool {
+	var passed bool
+	switch op {
+	case ">":
+		passed = value > limit
+	case ">=":
+		passed = value >= limit
+	case "<":
+		passed = value < limit
+	case "<=":
+		passed = value <= limit
+	}
+	if ctx.decision != nil {
+		ctx.decision.Checks = append(ctx.decision.Checks, Check{Name: name, Value: value, Op: op, Limit: limit, Passed: passed})
+	}
+	return passed
+}
+
+//step 记录计算步骤，返回计算结果
+func (ctx *Engine) step(name string, value float32) float32 {
+	if ctx.decision != nil {
+		ctx.decision.Steps = append(ctx.decision.Steps, Step{Name: name, Value: value})
+	}
+	return value
+}
+
+//cooldown 记录冷却期状态，返回是否处于冷却期。间隔按交易日计算
+func (ctx *Engine) cooldown(last, now time.Time, min int) bool {
+	days := TradingDaysBetween(last, now)
+	active := days < min
+	if ctx.decision != nil {
+		ctx.decision.Cooldown = &Cooldown{Last: last, Days: days, Min: min, Active: active}
+	}
+	return active
+}
+
+//decided 复制当日决策附加到交易记录
+func (ctx *Engine) decided(nw NetWorth, signal TransType) *Decision {
+	d := Decision{Date: nw.Date, NAV: nw.NAV, ROC: nw.ROC, VolaRoc: nw.VolaRoc}
+	if ctx.decision != nil && DiffDays(ctx.decision.Date, nw.Date) == 0 {
+		d = *ctx.decision
+		d.Checks = append([]Check(nil), d.Checks...)
+		d.Steps = append([]Step(nil), d.Steps...)
+		if d.Cooldown != nil {
+			cd := *d.Cooldown
+			d.Cooldown = &cd
+		}
+	}
+	d.Signal = signal
+	return &d
+}
+
+//skip 记录未执行的信号
+func (ctx *Engine) skip(nw NetWorth, signal TransType, reason string) {
+	d := ctx.decided(nw, signal)
+	d.Skipped = reason
+	ctx.skipped = append(ctx.skipped, *d)
+}
@@ -0,0 +1,47 @@
+package backtesting
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineDecision(t *testing.T) {
+	result := NewEngine(waveStrategy("2020-01-01", "2022-12-31"), waveNws("2020-01-01", 1000)).Run()
+	for _, tr := range result.TransList {
+		d := tr.Decision
+		assert.NotNil(t, d)
+		assert.Equal(t, tr.TransType, d.Signal)
+		assert.Equal(t, tr.NAV, d.NAV)
+		assert.Empty(t, d.Skipped)
+		switch tr.TransType {
+		case TransFixed:
+			last := d.Steps[len(d.Steps)-1]
+			assert.Equal(t, "向上取整到10元", last.Name)
+			assert.Equal(t, tr.Amount, last.Value)
+		case TransSell:
+			assert.Equal(t, "周期涨幅>卖出点", d.Checks[0].Name)
+			assert.True(t, d.Checks[0].Passed)
+			assert.NotEmpty(t, d.Steps)
+		}
+	}
+	var cooldown bool
+	for _, d := range result.Skipped {
+		assert.NotEmpty(t, d.Skipped)
+		if d.Skipped == "卖出冷却期" {
+			cooldown = true
+			assert.True(t, d.Cooldown.Active)
+			assert.Less(t, d.Cooldown.Days, d.Cooldown.Min)
+		}
+	}
+	assert.True(t, cooldown)
+
+	bts, err := json.Marshal(result.TransList)
+	assert.NoError(t, err)
+	again, _ := json.Marshal(result.TransList)
+	assert.Equal(t, bts, again)
+	var list TransactionList
+	assert.NoError(t, json.Unmarshal(bts, &list))
+	assert.Equal(t, result.TransList[0].Decision.Steps, list[0].Decision.Steps)
+}
@@ -2,6 +2,7 @@ package backtesting
 
 import (
 	"fmt"
+	"log"
 	"math"
 	"time"
 )
@@ -9,29 +10,51 @@ import (
 type (
 	//Engine 计算引擎
 	Engine struct {
-		strategy Strategy        //策略参数
-		nws      NetWorthList    //净值列表
-		trans    TransactionList //交易记录
-		shares   float32         //份额
-		invest   float32         //投入
-		profit   float32         //利润
-		balance  float32         //余额
-		value    float32         //资产总值
-		nav      float32         //当日净值
-		rop      float32         //利润率
-		date     time.Time       //当日日期
+		strategy   Strategy          //策略参数
+		nws        NetWorthList      //净值列表
+		trans      TransactionList   //交易记录
+		shares     float32           //份额
+		invest     float32           //投入
+		profit     float32           //利润
+		balance    float32           //余额
+		value      float32           //资产总值
+		nav        float32           //当日净值
+		rop        float32           //利润率
+		date       time.Time         //当日日期
+		schedule   *Schedule         //投入计划
+		flowed     time.Time         //一次性投入已计算到的日期
+		withdraw   *Withdrawal       //取出计划
+		drawing    withdrawing       //取出状态
+		rejects    []Reject          //被拒绝的委托
+		avail      AvailabilityList  //交易状态
+		serviceFee float32           //累计销售服务费
+		decision   *Decision         //当日的决策记录
+		skipped    []Decision        //未执行的信号
+		exchange   *Exchange         //场内交易规则，为nil时按场外基金交易
+		bars       map[string]Bar    //场内交易的K线
+		indexed    int               //已记录到lasts的交易数
+		lasts      map[TransType]int //各交易类型最后一次交易的位置
+		deposit    float32           //投入计划存入且尚未使用的资金，结果中计入余额
+		lotted     int               //已计入fifo的交易数
+		fifo       []Lot             //按先进先出计算的持仓批次，份额已按拆分折算
 	}
 	//Result 运行结果
 	Result struct {
-		Date      time.Time
-		Nav       float32
-		Invest    float32
-		Balance   float32
-		Value     float32
-		Shares    float32
-		Profit    float32
-		Rop       float32
-		TransList TransactionList
+		Date       time.Time
+		Nav        float32
+		Invest     float32
+		Balance    float32
+		Value      float32
+		Shares     float32
+		Profit     float32
+		Rop        float32
+		Withdrawn  float32   //累计取出金额
+		Depleted   time.Time //资金耗尽日期，未耗尽时为零值
+		TransList  TransactionList
+		Rejects    []Reject   //因交易限制被拒绝的委托
+		ServiceFee float32    //累计销售服务费
+		Skipped    []Decision //未执行的信号及原因
+		Err        error      //策略配置错误，不为nil时未进行交易
 	}
 )
 
@@ -44,8 +67,17 @@ func NewEngine(strategy Strategy, nws NetWorthList) *Engine {
 	}
 }
 
-//Run 运行回测
+//Run 运行回测。策略的投资周期配置错误时记录日志，不进行交易
 func (ctx *Engine) Run() Result {
+	//取出计划不按投资周期交易
+	if ctx.withdraw == nil {
+		if err := ctx.strategy.Validate(); err != nil {
+			log.Printf("策略%s的投资周期配置错误，不进行交易: %v", ctx.strategy.Code, err)
+			r := ctx.result()
+			r.Err = err
+			return r
+		}
+	}
 	for i := 0; i < len(ctx.nws); i++ {
 		nw := ctx.nws[i]
 		if DiffDays(nw.Date, ctx.strategy.StartDate) < 0 || DiffDays(nw.Date, ctx.strategy.EndDate) > 0 {
@@ -54,47 +86,107 @@ func (ctx *Engine) Run() Result {
 		// log.Println("日期", ctx.strategy.StartDate, ctx.strategy.EndDate, nw.Date)
 		ctx.runToday(i, nw)
 	}
+	return ctx.result()
+}
 
+func (ctx *Engine) result() Result {
 	return Result{
-		Date:      ctx.date,
-		Nav:       ctx.nav,
-		Invest:    ctx.invest,
-		Balance:   ctx.balance,
-		Value:     ctx.value,
-		Shares:    ctx.shares,
-		Profit:    ctx.profit,
-		Rop:       ctx.rop,
-		TransList: ctx.trans,
+		Date:       ctx.date,
+		Nav:        ctx.nav,
+		Invest:     ctx.invest,
+		Balance:    ctx.balance + ctx.deposit,
+		Value:      ctx.value,
+		Shares:     ctx.shares,
+		Profit:     ctx.profit,
+		Rop:        ctx.rop,
+		Withdrawn:  ctx.drawing.withdrawn,
+		Depleted:   ctx.drawing.depleted,
+		TransList:  ctx.trans,
+		Rejects:    ctx.rejects,
+		ServiceFee: ctx.serviceFee,
+		Skipped:    ctx.skipped,
 	}
 }
 
 //RunToday 执行当天的结果
 func (ctx *Engine) runToday(i int, nw NetWorth) {
+	ctx.begin(nw)
 	if nw.Splits > 0 {
 		ctx.spilit(nw)
 	}
-	if nw.Dividends > 0 {
+	ctx.serve(nw)
+	ctx.accrue(nw)
+	ctx.contribute(nw)
+	if ctx.withdraw != nil {
+		ctx.decumulate(nw)
+	} else if nw.Dividends > 0 {
 		ctx.dividends(nw)
-	} else if ctx.isSellDay(nw) {
+	} else {
+		ctx.trade(nw)
+	}
+	ctx.refresh(nw)
+}
+
+//lastTrans 交易类型的最后一次交易，只扫描上次之后新增的交易
+func (ctx *Engine) lastTrans(transType TransType) *Transaction {
+	if ctx.lasts == nil || ctx.indexed > len(ctx.trans) {
+		ctx.lasts, ctx.indexed = map[TransType]int{}, 0
+	}
+	for ; ctx.indexed < len(ctx.trans); ctx.indexed++ {
+		ctx.lasts[ctx.trans[ctx.indexed].TransType] = ctx.indexed
+	}
+	k, ok := ctx.lasts[transType]
+	if !ok {
+		return nil
+	}
+	trans := ctx.trans[k]
+	return &trans
+}
+
+//trade 按策略信号卖出、定投或追加买入
+func (ctx *Engine) trade(nw NetWorth) {
+	if ctx.isSellDay(nw) {
 		shares := ctx.recoSell(nw)
 		ctx.sell(shares, nw)
 	} else if ctx.isBuyDay(nw) {
 		amount := ctx.recoBuy(nw)
+		if amount <= 0 {
+			ctx.skip(nw, TransFixed, "建议金额为0")
+		}
 		ctx.fixed(amount, nw)
 	} else if ctx.isAppendDay(nw) {
 		var amount = ctx.recoAppend(nw)
+		if amount <= 0 {
+			ctx.skip(nw, TransAppend, "建议金额为0")
+		}
 		ctx.append(amount, nw)
 	}
-	ctx.refresh(nw)
 }
 
 func (ctx *Engine) fixed(amount float32, nw NetWorth) *Transaction {
+	if amount = ctx.purchasable(amount, nw, TransFixed); amount <= 0 {
+		return nil
+	}
+	ctx.pay(amount)
+	return ctx.buy(nw, amount, ctx.buyRate(), TransFixed)
+}
+
+//pay 支付买入金额。先使用投入计划存入的资金，其余在余额足够时使用余额，否则为新投入
+func (ctx *Engine) pay(amount float32) {
+	if use := ctx.deposit; use > 0 {
+		if use > amount {
+			use = amount
+		}
+		ctx.deposit -= use
+		if amount -= use; amount <= 0 {
+			return
+		}
+	}
 	if ctx.balance > amount {
 		ctx.balance -= amount
 	} else {
 		ctx.invest += amount
 	}
-	return ctx.buy(nw, amount, ctx.strategy.TransRate, TransFixed)
 }
 
 func (ctx *Engine) buy(nw NetWorth, amount, transrate float32, transType TransType) *Transaction {
@@ -103,7 +195,10 @@ func (ctx *Engine) buy(nw NetWorth, amount, transrate float32, transType TransTy
 	}
 	nav := nw.NAV
 	transfee := ParseFloat32(fmt.Sprintf("%.2f", amount*transrate/100))
-	shares := ParseFloat32(fmt.Sprintf("%.2f", (amount-transfee)/nav))
+	shares := ctx.strategy.Order.roundShares((amount - transfee) / nav)
+	if ctx.exchange != nil && transType != TransDividends {
+		shares, transfee = ctx.exchange.lots(amount, nav)
+	}
 	trans := Transaction{
 		Date:      nw.Date,
 		Amount:    amount,
@@ -111,9 +206,7 @@ func (ctx *Engine) buy(nw NetWorth, amount, transrate float32, transType TransTy
 		TransFee:  transfee,
 		Shares:    shares,
 		TransType: transType,
-		Args: map[string]interface{}{
-			"VolaRoc": nw.VolaRoc,
-		},
+		Decision:  ctx.decided(nw, transType),
 	}
 	ctx.trans.Append(trans)
 	ctx.shares += shares
@@ -121,98 +214,117 @@ func (ctx *Engine) buy(nw NetWorth, amount, transrate float32, transType TransTy
 }
 
 func (ctx *Engine) sell(shares float32, nw NetWorth) *Transaction {
+	if shares = ctx.redeemable(shares, nw, TransSell); shares <= 0 {
+		return nil
+	}
 	amount := nw.NAV * shares
+	transfee := ParseFloat32(fmt.Sprintf("%.2f", ctx.redeemFee(shares, nw)))
 	ctx.shares -= shares
 	trans := Transaction{
 		Date:      nw.Date,
 		Amount:    amount,
 		NAV:       nw.NAV,
-		TransFee:  0,
+		TransFee:  transfee,
 		Shares:    shares,
 		TransType: TransSell,
-		Args: map[string]interface{}{
-			"VolaRoc": nw.VolaRoc,
-		},
+		Decision:  ctx.decided(nw, TransSell),
 	}
-	ctx.balance += amount
+	ctx.balance += amount - transfee
 	ctx.trans.Append(trans)
 	return &trans
 }
 
 func (ctx *Engine) append(amount float32, nw NetWorth) *Transaction {
-	if ctx.balance > amount {
-		ctx.balance -= amount
-	} else {
-		ctx.invest += amount
+	if amount = ctx.purchasable(amount, nw, TransAppend); amount <= 0 {
+		return nil
 	}
-	return ctx.buy(nw, amount, ctx.strategy.TransRate, TransAppend)
+	ctx.pay(amount)
+	return ctx.buy(nw, amount, ctx.buyRate(), TransAppend)
 }
 
 func (ctx *Engine) dividends(nw NetWorth) *Transaction {
 	amount := nw.Dividends * ctx.shares
+	if ctx.exchange != nil {
+		//场内为现金分红，计入余额
+		trans := Transaction{
+			Date:      nw.Date,
+			Amount:    amount,
+			NAV:       nw.NAV,
+			TransType: TransDividends,
+			Decision:  ctx.decided(nw, TransDividends),
+		}
+		ctx.balance += amount
+		ctx.trans.Append(trans)
+		return &trans
+	}
 	return ctx.buy(nw, amount, 0, TransDividends)
 }
 
 func (ctx *Engine) spilit(nw NetWorth) {
 	ctx.shares = ctx.shares * nw.Splits
+	lots := ctx.lots()
+	for k := range lots {
+		lots[k].Shares *= nw.Splits
+	}
 }
 
 //recoBuy 推荐购买金额
 func (ctx *Engine) recoBuy(nw NetWorth) float32 {
-	amount := ctx.strategy.BasicAmount
-	if ctx.strategy.FixedMethod == FloatInvest {
+	amount := ctx.step("基准金额", ctx.basicAmount(nw.Date))
+	if ctx.strategy.FixedMethod == FloatInvest && !ctx.strategy.Disabled(RuleFloat) {
 		amount = ctx.amountB(nw)
 	}
-	if amount > ctx.strategy.MaxAmount {
-		amount = ctx.strategy.MaxAmount
-	} else if amount < ctx.strategy.MinAmount {
-		amount = ctx.strategy.MinAmount
+	growth := ctx.growth(nw.Date)
+	if ctx.check("金额>最大投入", amount, ">", ctx.strategy.MaxAmount*growth) {
+		amount = ctx.step("按最大投入", ctx.strategy.MaxAmount*growth)
+	} else if ctx.check("金额<最小投入", amount, "<", ctx.strategy.MinAmount*growth) {
+		amount = ctx.step("按最小投入", ctx.strategy.MinAmount*growth)
 	} else if amount < 100 {
-		amount = 0
+		amount = ctx.step("金额不足100", 0)
 	}
-	return float32(int(math.Ceil(float64(amount)/10) * 10))
+	return ctx.step("向上取整到10元", float32(int(math.Ceil(float64(amount)/10)*10)))
 }
 
 func (ctx *Engine) amountB(nw NetWorth) float32 {
-	var amount = ctx.strategy.BasicAmount
+	var amount = ctx.basicAmount(nw.Date)
 	roc := nw.VolaRoc
 	if roc == 0 {
 		return amount
 	}
 	//考虑6%的gdp增长
 	// roc -= 0.06
-	r := amount*roc/100 + amount/-roc/100
+	r := ctx.step("涨跌系数", amount*roc/100+amount/-roc/100)
 	if r > 30 {
-		return amount - r*3
+		return ctx.step("系数>30 基准-系数*3", amount-r*3)
 	} else if r > 20 {
-		return amount - r*2
+		return ctx.step("系数>20 基准-系数*2", amount-r*2)
 	} else if r > 0 {
-		return amount - r*1.5
+		return ctx.step("系数>0 基准-系数*1.5", amount-r*1.5)
 	}
-	r *= 10 //下跌的话，追加金额是系数的10倍。
+	r = ctx.step("下跌系数*10", r*10) //下跌的话，追加金额是系数的10倍。
 	if roc < -40 {
-		return amount - r*10
+		return ctx.step("周期跌幅<-40 基准-系数*10", amount-r*10)
 	} else if roc < -30 {
-		return amount - r*8
+		return ctx.step("周期跌幅<-30 基准-系数*8", amount-r*8)
 	} else if roc < -25 {
-		return amount - r*6
+		return ctx.step("周期跌幅<-25 基准-系数*6", amount-r*6)
 	} else if roc < -20 {
-		return amount - r*4
+		return ctx.step("周期跌幅<-20 基准-系数*4", amount-r*4)
 	} else if roc < -10 {
-		return amount - r*2
+		return ctx.step("周期跌幅<-10 基准-系数*2", amount-r*2)
 	}
-	return amount - r*1.5
+	return ctx.step("基准-系数*1.5", amount-r*1.5)
 }
 
 func (ctx *Engine) recoSell(nw NetWorth) float32 {
 	//卖多少
-	var last = ctx.trans.LastSell()
-	var shares = ctx.shares * 0.1
-	//如果30天内，有卖出，则卖出份额在上次卖出份额的基础上增加卖出期间的涨幅
-	if last != nil && nw.NAV > last.NAV && nw.Date.Sub(last.Date).Hours()/24 < 30 {
+	var last = ctx.lastTrans(TransSell)
+	var shares = ctx.step("持有份额*10%", ctx.shares*0.1)
+	//如果21个交易日（约一个月）内，有卖出，则卖出份额在上次卖出份额的基础上增加卖出期间的涨幅
+	if last != nil && nw.NAV > last.NAV && TradingDaysBetween(last.Date, nw.Date) < 21 {
 		//当前相当于上次卖出涨幅确定卖出比例
 		//如果卖出在一个月以内，则加上较上次卖出涨幅比例+0.1
-		shares = last.Shares * (1 + (nw.NAV-last.NAV)/last.NAV)
+		shares = ctx.step("上次卖出份额*(1+期间涨幅)", last.Shares*(1+(nw.NAV-last.NAV)/last.NAV))
 	}
 	return shares
 }
@@ -222,8 +334,11 @@ func (ctx *Engine) recoAppend(nw NetWorth) float32 {
 }
 
 func (ctx *Engine) isBuyDay(nw NetWorth) bool {
-	var last interface{}
-	trans := ctx.trans.LastBuy()
+	if ctx.paused(nw.Date) {
+		return false
+	}
+	var last time.Time
+	trans := ctx.lastTrans(TransFixed)
 	if trans != nil {
 		last = trans.Date
 	}
@@ -231,16 +346,26 @@ func (ctx *Engine) isBuyDay(nw NetWorth) bool {
 }
 
 func (ctx *Engine) isSellDay(nw NetWorth) bool {
-	last := ctx.trans.LastSell()
-	r := nw.VolaRoc > ctx.strategy.SellPoint
-	if last != nil {
-		r = r && nw.Date.Sub(last.Date).Hours()/24 > 10
+	if ctx.strategy.Disabled(RuleSell) {
+		return false
+	}
+	last := ctx.lastTrans(TransSell)
+	r := ctx.check("周期涨幅>卖出点", nw.VolaRoc, ">", ctx.strategy.SellPoint)
+	//7个交易日（约10天）内卖出过的不再卖出
+	if last != nil && ctx.cooldown(last.Date, nw.Date, 8) {
+		if r {
+			ctx.skip(nw, TransSell, "卖出冷却期")
+		}
+		r = false
 	}
 	return r
 }
 
 func (ctx *Engine) isAppendDay(nw NetWorth) bool {
-	if nw.ROC < -3 && nw.VolaRoc < 10 {
+	if ctx.paused(nw.Date) || ctx.strategy.Disabled(RuleAppend) {
+		return false
+	}
+	if ctx.check("当日涨幅<-3", nw.ROC, "<", -3) && ctx.check("周期涨幅<10", nw.VolaRoc, "<", 10) {
 		return true
 	}
 	return false
@@ -249,8 +374,13 @@ func (ctx *Engine) isAppendDay(nw NetWorth) bool {
 func (ctx *Engine) refresh(nw NetWorth) {
 	ctx.date = nw.Date
 	ctx.nav = nw.NAV
-	ctx.value = ctx.shares*ctx.nav + ctx.balance
-	ctx.profit = ctx.value - ctx.invest
+	ctx.revalue()
+}
+
+//revalue 按当前净值更新资产总值及利润
+func (ctx *Engine) revalue() {
+	ctx.value = ctx.shares*ctx.nav + ctx.balance + ctx.deposit
+	ctx.profit = ctx.value + ctx.drawing.withdrawn - ctx.invest
 	ctx.rop = ctx.profit / ctx.invest * 100
 }
 
@@ -0,0 +1,189 @@
+package backtesting
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type (
+	//Bar 场内交易的日K线
+	Bar struct {
+		Date      time.Time
+		Open      float32
+		High      float32
+		Low       float32
+		Close     float32
+		PreClose  float32 //前收盘价，除权后的价格。为0时使用上一日收盘价
+		Volume    float64 //成交量，为0时视为停牌
+		Dividends float32 //每份现金分红
+		Splits    float32 //拆分比例
+	}
+	//BarList K线列表
+	BarList []Bar
+	//Exchange 场内交易规则，以收盘价成交
+	Exchange struct {
+		Lot           int     //每手份数，默认100
+		Commission    float32 //佣金费率(%)
+		MinCommission float32 //单笔最低佣金，为0时为5元，不设最低佣金时为负数
+		Limit         float32 //涨跌停幅度(%)，默认10
+		T0            bool    //当日买入可当日卖出，如跨境及债券ETF
+	}
+)
+
+//NetWorths 转为以收盘价为净值的净值列表，涨跌幅按前收盘价计算
+func (items BarList) NetWorths() NetWorthList {
+	nws := make(NetWorthList, 0, len(items))
+	for k, bar := range items {
+		pre := bar.PreClose
+		if pre == 0 && k > 0 {
+			pre = items[k-1].Close
+		}
+		var roc float32
+		if pre > 0 {
+			roc = (bar.Close/pre - 1) * 100
+		}
+		nws = append(nws, NetWorth{
+			Date:      bar.Date,
+			NAV:       bar.Close,
+			CNAV:      bar.Close,
+			ROC:       roc,
+			Dividends: bar.Dividends,
+			Splits:    bar.Splits,
+		})
+	}
+	return nws
+}
+
+//NewBarEngine 创建场内交易的回测引擎，定投金额按整手买入，沿用基金的仓位计算
+func NewBarEngine(strategy Strategy, bars BarList, ex Exchange) *Engine {
+	e := NewEngine(strategy, bars.NetWorths())
+	e.exchange = &ex
+	e.bars = make(map[string]Bar, len(bars))
+	for k, bar := range bars {
+		if bar.PreClose == 0 && k > 0 {
+			bar.PreClose = bars[k-1].Close
+		}
+		e.bars[DateToString(bar.Date)] = bar
+	}
+	return e
+}
+
+func (ex Exchange) lot() float32 {
+	if ex.Lot <= 0 {
+		return 100
+	}
+	return float32(ex.Lot)
+}
+
+func (ex Exchange) limit() float32 {
+	if ex.Limit <= 0 {
+		return 10
+	}
+	return ex.Limit
+}
+
+//commission 成交金额的佣金
+func (ex Exchange) commission(value float32) float32 {
+	fee := ParseFloat32(fmt.Sprintf("%.2f", value*ex.Commission/100))
+	min := ex.MinCommission
+	if min == 0 {
+		min = 5
+	}
+	if fee < min {
+		fee = min
+	}
+	return fee
+}
+
+//lots 金额可买入的整手份数及佣金，金额包含佣金
+func (ex Exchange) lots(amount, price float32) (shares, fee float32) {
+	if price <= 0 {
+		return 0, 0
+	}
+	lot := ex.lot()
+	for n := float32(math.Floor(float64(amount / (price * lot)))); n > 0; n-- {
+		shares = n * lot
+		fee = ex.commission(shares * price)
+		if shares*price+fee <= amount+1e-3 {
+			return shares, fee
+		}
+	}
+	return 0, 0
+}
+
+//limited 收盘价是否封涨停或跌停，up为涨停
+func (ex Exchange) limited(bar Bar, up bool) bool {
+	if bar.PreClose <= 0 {
+		return false
+	}
+	rate := ex.limit() / 100
+	if up {
+		price := float32(math.Round(float64(bar.PreClose*(1+rate))*1000) / 1000)
+		return bar.Close >= price-1e-4
+	}
+	price := float32(math.Round(float64(bar.PreClose*(1-rate))*1000) / 1000)
+	return bar.Close <= price+1e-4
+}
+
+//buyable 按场内规则调整买入金额为整手的成交金额，不满足时记录拒绝原因并返回0
+func (ctx *Engine) buyable(amount float32, nw NetWorth, transType TransType) float32 {
+	ex := ctx.exchange
+	bar := ctx.bars[DateToString(nw.Date)]
+	switch {
+	case bar.Volume <= 0:
+		ctx.reject(nw, transType, amount, 0, "停牌")
+		return 0
+	case ex.limited(bar, true):
+		ctx.reject(nw, transType, amount, 0, "涨停无法买入")
+		return 0
+	}
+	shares, fee := ex.lots(amount, nw.NAV)
+	if shares <= 0 {
+		ctx.reject(nw, transType, amount, 0, "金额不足一手")
+		return 0
+	}
+	return shares*nw.NAV + fee
+}
+
+//sellable 按场内规则调整卖出份额，不足一手的零股只能一次卖出，不满足时记录拒绝原因并返回0
+func (ctx *Engine) sellable(shares float32, nw NetWorth, transType TransType) float32 {
+	ex := ctx.exchange
+	bar := ctx.bars[DateToString(nw.Date)]
+	switch {
+	case bar.Volume <= 0:
+		ctx.reject(nw, transType, shares*nw.NAV, shares, "停牌")
+		return 0
+	case ex.limited(bar, false):
+		ctx.reject(nw, transType, shares*nw.NAV, shares, "跌停无法卖出")
+		return 0
+	}
+	available := ctx.shares
+	if !ex.T0 {
+		available -= ctx.bought(nw.Date)
+	}
+	if available <= 0 {
+		ctx.reject(nw, transType, shares*nw.NAV, shares, "T+1当日买入不可卖出")
+		return 0
+	}
+	if shares >= available {
+		return available
+	}
+	lot := ex.lot()
+	if n := float32(math.Floor(float64(shares/lot))) * lot; n > 0 {
+		return n
+	}
+	ctx.reject(nw, transType, shares*nw.NAV, shares, "份额不足一手")
+	return 0
+}
+
+//bought 当日买入的份额
+func (ctx *Engine) bought(date time.Time) float32 {
+	var shares float32
+	for i := len(ctx.trans) - 1; i >= 0 && DiffDays(ctx.trans[i].Date, date) == 0; i-- {
+		if t := ctx.trans[i]; t.TransType == TransFixed || t.TransType == TransAppend || t.TransType == TransContribute {
+			shares += t.Shares
+		}
+	}
+	return shares
+}
@@ -0,0 +1,122 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flatBars(start string, days int, price float32) BarList {
+	var bars BarList
+	date := ParseDate(start)
+	for i := 0; i < days; i++ {
+		bars = append(bars, Bar{Date: date.AddDate(0, 0, i), Open: price, High: price, Low: price, Close: price, Volume: 10000})
+	}
+	return bars
+}
+
+func TestBarListNetWorths(t *testing.T) {
+	bars := BarList{
+		{Date: ParseDate("2021-01-04"), Close: 1.5},
+		{Date: ParseDate("2021-01-05"), Close: 1.65},
+		{Date: ParseDate("2021-01-06"), Close: 1.5, PreClose: 1.6, Dividends: 0.05},
+	}
+	nws := bars.NetWorths()
+	assert.Len(t, nws, 3)
+	assert.Equal(t, float32(0), nws[0].ROC)
+	assert.InDelta(t, 10, nws[1].ROC, 0.001)
+	//除权后按前收盘价计算
+	assert.InDelta(t, -6.25, nws[2].ROC, 0.001)
+	assert.Equal(t, float32(0.05), nws[2].Dividends)
+}
+
+func TestExchangeLots(t *testing.T) {
+	ex := Exchange{Commission: 0.025}
+	shares, fee := ex.lots(1000, 1.234)
+	assert.Equal(t, float32(800), shares)
+	assert.Equal(t, float32(5), fee)
+	//不足一手
+	shares, _ = ex.lots(120, 1.234)
+	assert.Equal(t, float32(0), shares)
+	//扣除最低佣金后不足
+	shares, _ = ex.lots(124, 1.2)
+	assert.Equal(t, float32(0), shares)
+	assert.Equal(t, float32(25), ex.commission(100000))
+	assert.Equal(t, float32(0.25), Exchange{Commission: 0.025, MinCommission: -1}.commission(1000))
+
+	assert.True(t, ex.limited(Bar{PreClose: 1.234, Close: 1.357}, true))
+	assert.False(t, ex.limited(Bar{PreClose: 1.234, Close: 1.356}, true))
+	assert.True(t, ex.limited(Bar{PreClose: 1.234, Close: 1.111}, false))
+	assert.True(t, Exchange{Limit: 20}.limited(Bar{PreClose: 1, Close: 0.8}, false))
+}
+
+func TestBarEngine(t *testing.T) {
+	bars := flatBars("2021-01-01", 90, 1.234)
+	s := Strategy{
+		Code:        "163406",
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		StartDate:   ParseDate("2021-01-01"),
+		EndDate:     ParseDate("2021-03-20"),
+		CycleType:   CycleMonth,
+		CycleValue:  5,
+	}
+	result := NewBarEngine(s, bars, Exchange{Commission: 0.025}).Run()
+	fixed := result.TransList[0]
+	assert.Equal(t, TransFixed, fixed.TransType)
+	assert.Equal(t, float32(800), fixed.Shares)
+	assert.Equal(t, float32(5), fixed.TransFee)
+	assert.InDelta(t, 800*1.234+5, fixed.Amount, 0.001)
+	//只投入整手的成交金额
+	assert.InDelta(t, fixed.Amount*3, result.Invest, 0.01)
+	assert.Equal(t, float32(2400), result.Shares)
+
+	//涨停及停牌不买入
+	bars = flatBars("2021-01-01", 40, 1)
+	bars[4].Close, bars[4].PreClose = 1.1, 1
+	bars[5].PreClose, bars[5].Volume = 1.1, 0
+	e := NewBarEngine(s, bars, Exchange{Commission: 0.025})
+	result = e.Run()
+	assert.Len(t, result.Rejects, 2)
+	assert.Equal(t, "涨停无法买入", result.Rejects[0].Reason)
+	assert.Equal(t, "停牌", result.Rejects[1].Reason)
+	assert.Equal(t, "2021-01-07", DateToString(result.TransList[0].Date))
+}
+
+func TestBarEngineSell(t *testing.T) {
+	bars := flatBars("2021-01-01", 10, 1)
+	e := NewBarEngine(Strategy{}, bars, Exchange{Commission: 0.025})
+	nws := e.nws
+	e.fixed(1000, nws[0])
+	assert.Equal(t, float32(900), e.shares)
+	//T+1当日买入的份额不可卖出
+	trans := e.sell(1800, nws[0])
+	assert.Nil(t, trans)
+	assert.Equal(t, "T+1当日买入不可卖出", e.rejects[0].Reason)
+	e.fixed(1000, nws[1])
+	trans = e.sell(1800, nws[1])
+	assert.Equal(t, float32(900), trans.Shares)
+	e.shares = 1850
+	//整手卖出，零股只能一次卖出
+	trans = e.sell(250, nws[2])
+	assert.Equal(t, float32(200), trans.Shares)
+	assert.Equal(t, float32(5), trans.TransFee)
+	assert.Nil(t, e.sell(50, nws[3]))
+	trans = e.sell(2000, nws[3])
+	assert.Equal(t, float32(1650), trans.Shares)
+
+	//跌停无法卖出
+	e.shares = 1000
+	e.bars[DateToString(nws[4].Date)] = Bar{Date: nws[4].Date, Close: 0.9, PreClose: 1, Volume: 1}
+	assert.Nil(t, e.sell(500, nws[4]))
+	assert.Equal(t, "跌停无法卖出", e.rejects[len(e.rejects)-1].Reason)
+
+	//现金分红计入余额
+	e.balance = 0
+	nw := nws[5]
+	nw.Dividends = 0.02
+	e.dividends(nw)
+	assert.Equal(t, float32(20), e.balance)
+	assert.Equal(t, float32(1000), e.shares)
+}
@@ -0,0 +1,171 @@
+package backtesting
+
+type (
+	//FeeSchedule 份额类别的费率，设置后替代策略的TransRate和SellRate
+	FeeSchedule struct {
+		Front   float32      //申购费率(%)，A类收取
+		Service float32      //年化销售服务费率(%)，C类按日计提。使用C类自身净值时应为0，净值已扣除服务费
+		Redeem  []RedeemTier //按持有天数递增排列的赎回费率，超过最后一档的天数免收
+	}
+	//RedeemTier 赎回费率档位
+	RedeemTier struct {
+		Days int     //持有天数少于该天数时适用
+		Rate float32 //赎回费率(%)
+	}
+	//ShareClass 基金的份额类别
+	ShareClass struct {
+		Code string
+		Fee  FeeSchedule
+		Nws  NetWorthList
+	}
+	//ClassPoint 持有到指定天数后全部赎回的净收益
+	ClassPoint struct {
+		Days   int
+		Profit [2]float32 //两个类别扣除全部费用后的收益
+	}
+	//ClassComparison 份额类别对比结果
+	ClassComparison struct {
+		Classes   [2]ShareClass
+		Points    []ClassPoint
+		Breakeven int //第一个类别开始持续优于第二个类别的持有天数，区间内始终不优于时为-1
+	}
+)
+
+//enabled 是否设置了份额类别费率
+func (f FeeSchedule) enabled() bool {
+	return f.Front > 0 || f.Service > 0 || len(f.Redeem) > 0
+}
+
+//redeemRate 持有days天的赎回费率(%)
+func (f FeeSchedule) redeemRate(days int) float32 {
+	for _, tier := range f.Redeem {
+		if days < tier.Days {
+			return tier.Rate
+		}
+	}
+	return 0
+}
+
+//buyRate 申购费率(%)
+func (ctx *Engine) buyRate() float32 {
+	if ctx.strategy.Fee.enabled() {
+		return ctx.strategy.Fee.Front
+	}
+	return ctx.strategy.TransRate
+}
+
+//redeemFee 赎回份额的费用，未取整。设置了份额类别时按先进先出的持有天数分档计算，场内交易为佣金
+func (ctx *Engine) redeemFee(shares float32, nw NetWorth) float32 {
+	if ctx.exchange != nil {
+		return ctx.exchange.commission(nw.NAV * shares)
+	}
+	fee := nw.NAV * shares * ctx.strategy.SellRate / 100
+	if f := ctx.strategy.Fee; f.enabled() {
+		fee = 0
+		for _, lot := range ctx.lots() {
+			if shares <= 0 {
+				break
+			}
+			part := lot.Shares
+			if part > shares {
+				part = shares
+			}
+			fee += nw.NAV * part * f.redeemRate(DiffDays(nw.Date, lot.Date)) / 100
+			shares -= part
+		}
+	}
+	return fee
+}
+
+//sellRate 赎回份额的平均赎回费率(%)
+func (ctx *Engine) sellRate(shares float32, nw NetWorth) float32 {
+	if !ctx.strategy.Fee.enabled() && ctx.exchange == nil {
+		return ctx.strategy.SellRate
+	}
+	if shares > ctx.shares {
+		shares = ctx.shares
+	}
+	if shares <= 0 || nw.NAV <= 0 {
+		return 0
+	}
+	return ctx.redeemFee(shares, nw) / (shares * nw.NAV) * 100
+}
+
+//lots 按先进先出计算的持仓批次，只计入上次之后新增的交易，拆分时由spilit折算已有批次的份额
+func (ctx *Engine) lots() []Lot {
+	if ctx.lotted > len(ctx.trans) {
+		ctx.fifo, ctx.lotted = nil, 0
+	}
+	for ; ctx.lotted < len(ctx.trans); ctx.lotted++ {
+		t := ctx.trans[ctx.lotted]
+		switch t.TransType {
+		case TransFixed, TransAppend, TransContribute, TransDividends:
+			ctx.fifo = append(ctx.fifo, Lot{Date: t.Date, Shares: t.Shares, Cost: t.Amount})
+		case TransSwitch:
+			if t.Shares > 0 {
+				ctx.fifo = append(ctx.fifo, Lot{Date: t.Date, Shares: t.Shares, Cost: t.Amount})
+			} else {
+				ctx.fifo = consume(ctx.fifo, -t.Shares)
+			}
+		case TransSell, TransWithdraw:
+			ctx.fifo = consume(ctx.fifo, t.Shares)
+		}
+	}
+	return ctx.fifo
+}
+
+//serve 按日计提C类的销售服务费，从份额中扣除
+func (ctx *Engine) serve(nw NetWorth) {
+	rate := ctx.strategy.Fee.Service
+	if rate <= 0 || ctx.shares <= 0 || ctx.date.IsZero() {
+		return
+	}
+	days := DiffDays(nw.Date, ctx.date)
+	if days <= 0 {
+		return
+	}
+	shares := ctx.shares * rate / 100 * float32(days) / 365
+	ctx.shares -= shares
+	ctx.serviceFee += shares * nw.NAV
+}
+
+//liquidate 按当前净值全部赎回后的到手金额，包含余额
+func (ctx *Engine) liquidate() float32 {
+	fee := ctx.redeemFee(ctx.shares, NetWorth{Date: ctx.date, NAV: ctx.nav})
+	return ctx.shares*ctx.nav - fee + ctx.balance
+}
+
+//CompareClasses 在两个份额类别上运行同一策略，按持有天数对比全部赎回后的净收益。step为对比的天数间隔，默认30天
+func CompareClasses(s Strategy, a, c ShareClass, step int) ClassComparison {
+	if step <= 0 {
+		step = 30
+	}
+	cmp := ClassComparison{Classes: [2]ShareClass{a, c}, Breakeven: -1}
+	end := s.EndDate
+	for _, class := range cmp.Classes {
+		if n := len(class.Nws); n > 0 && (end.IsZero() || class.Nws[n-1].Date.Before(end)) {
+			end = class.Nws[n-1].Date
+		}
+	}
+	for days := step; DiffDays(end, s.StartDate) >= days; days += step {
+		point := ClassPoint{Days: days}
+		for k, class := range cmp.Classes {
+			st := s
+			st.Code = class.Code
+			st.Fee = class.Fee
+			st.EndDate = s.StartDate.AddDate(0, 0, days)
+			e := NewEngine(st, class.Nws)
+			r := e.Run()
+			point.Profit[k] = e.liquidate() + r.Withdrawn - r.Invest
+		}
+		cmp.Points = append(cmp.Points, point)
+	}
+	for k := len(cmp.Points) - 1; k >= 0; k-- {
+		p := cmp.Points[k]
+		if p.Profit[0] < p.Profit[1] {
+			break
+		}
+		cmp.Breakeven = p.Days
+	}
+	return cmp
+}
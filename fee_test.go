@@ -0,0 +1,84 @@
+package backtesting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//growNws 按年化收益率稳定增长的净值
+func growNws(start string, days int, rate float64) NetWorthList {
+	var nws NetWorthList
+	date := ParseDate(start)
+	for i := 0; i < days; i++ {
+		nav := float32(math.Pow(1+rate/100, float64(i)/365))
+		nws = append(nws, NetWorth{Date: date.AddDate(0, 0, i), NAV: nav, CNAV: nav, ROC: float32(rate / 365)})
+	}
+	return nws
+}
+
+func TestFeeScheduleRedeemRate(t *testing.T) {
+	f := FeeSchedule{Redeem: []RedeemTier{{Days: 7, Rate: 1.5}, {Days: 30, Rate: 0.5}}}
+	assert.Equal(t, float32(1.5), f.redeemRate(3))
+	assert.Equal(t, float32(0.5), f.redeemRate(7))
+	assert.Equal(t, float32(0), f.redeemRate(30))
+}
+
+func TestEngineRedeemFee(t *testing.T) {
+	s := Strategy{Fee: FeeSchedule{Front: 1, Redeem: []RedeemTier{{Days: 7, Rate: 1.5}, {Days: 30, Rate: 0.5}}}}
+	e := NewEngine(s, flatNws("2020-01-01", 60, 1))
+	e.fixed(1000, e.nws[0])
+	assert.Equal(t, float32(990), e.shares)
+	e.fixed(1000, e.nws[25])
+	//先卖出最早的批次，持有40天免赎回费，其余持有15天
+	trans := e.sell(1500, e.nws[40])
+	assert.Equal(t, float32(2.55), trans.TransFee)
+
+	//拆分后各批次的份额同样折算
+	e = NewEngine(s, flatNws("2020-01-01", 60, 1))
+	e.fixed(1000, e.nws[0])
+	e.fixed(1000, e.nws[25])
+	split := e.nws[30]
+	split.Splits = 2
+	e.spilit(split)
+	nw := e.nws[40]
+	nw.NAV = 0.5
+	trans = e.sell(3000, nw)
+	assert.Equal(t, float32(2.55), trans.TransFee)
+
+	s.Fee = FeeSchedule{Service: 36.5}
+	e = NewEngine(s, flatNws("2020-01-01", 60, 1))
+	e.fixed(1000, e.nws[0])
+	e.refresh(e.nws[0])
+	e.serve(e.nws[10])
+	assert.InDelta(t, 990, e.shares, 0.01)
+	assert.InDelta(t, 10, e.serviceFee, 0.01)
+}
+
+func TestCompareClasses(t *testing.T) {
+	nws := growNws("2018-01-01", 365*5, 6)
+	s := Strategy{
+		BasicAmount: 1000,
+		MinAmount:   1000,
+		MaxAmount:   1000,
+		StartDate:   ParseDate("2018-01-01"),
+		EndDate:     ParseDate("2022-12-31"),
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		FixedMethod: FixedInvest,
+	}
+	a := ShareClass{Code: "A", Nws: nws, Fee: FeeSchedule{Front: 1.2, Redeem: []RedeemTier{{Days: 7, Rate: 1.5}, {Days: 365, Rate: 0.5}}}}
+	c := ShareClass{Code: "C", Nws: nws, Fee: FeeSchedule{Service: 1, Redeem: []RedeemTier{{Days: 7, Rate: 1.5}, {Days: 30, Rate: 0.5}}}}
+	cmp := CompareClasses(s, a, c, 90)
+	assert.NotEmpty(t, cmp.Points)
+	first, last := cmp.Points[0], cmp.Points[len(cmp.Points)-1]
+	assert.Less(t, first.Profit[0], first.Profit[1])
+	assert.Greater(t, last.Profit[0], last.Profit[1])
+	assert.Greater(t, cmp.Breakeven, first.Days)
+	for _, p := range cmp.Points {
+		if p.Days >= cmp.Breakeven {
+			assert.GreaterOrEqual(t, p.Profit[0], p.Profit[1])
+		}
+	}
+}
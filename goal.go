@@ -0,0 +1,119 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+type (
+	//Goal 投资目标
+	Goal struct {
+		Target     float32   //目标金额
+		Date       time.Time //目标日期
+		Confidence float64   //要求达成目标的概率，如0.8
+		MinAmount  float32   //搜索的最小每期投入，默认100
+		MaxAmount  float32   //搜索的最大每期投入，默认为目标金额
+	}
+	//GoalResult 目标求解结果
+	GoalResult struct {
+		Amount      float32  //需要的每期投入金额
+		Probability float64  //按该金额投入达成目标的概率
+		Reached     bool     //搜索范围内是否能达到要求的概率
+		Strategy    Strategy //采用的策略参数
+	}
+	//GoalRunner 按每期投入金额在第path条路径上运行回测，返回目标日期的资产价值
+	GoalRunner func(amount float32, path int) float32
+)
+
+//SolveGoal 二分搜索达到目标概率所需的最小每期投入
+func SolveGoal(goal Goal, paths int, run GoalRunner) GoalResult {
+	lo, hi := goal.MinAmount, goal.MaxAmount
+	if lo <= 0 {
+		lo = 100
+	}
+	if hi <= 0 {
+		hi = goal.Target
+	}
+	result := GoalResult{Amount: hi, Probability: goal.probability(hi, paths, run)}
+	if result.Probability < goal.Confidence {
+		return result
+	}
+	result.Reached = true
+	if p := goal.probability(lo, paths, run); p >= goal.Confidence {
+		result.Amount, result.Probability = lo, p
+		return result
+	}
+	//按10元精度搜索
+	for hi-lo > 10 {
+		mid := float32(math.Ceil(float64(lo+hi)/20) * 10)
+		if mid >= hi {
+			break
+		}
+		if p := goal.probability(mid, paths, run); p >= goal.Confidence {
+			hi = mid
+			result.Amount, result.Probability = mid, p
+		} else {
+			lo = mid
+		}
+	}
+	return result
+}
+
+//probability 按指定投入金额达成目标的路径比例
+func (goal Goal) probability(amount float32, paths int, run GoalRunner) float64 {
+	if paths <= 0 {
+		return 0
+	}
+	reached := 0
+	for i := 0; i < paths; i++ {
+		if run(amount, i) >= goal.Target {
+			reached++
+		}
+	}
+	return float64(reached) / float64(paths)
+}
+
+//EngineRunner 单基金引擎的目标运行器，最大最小投入按基准金额同比例调整
+func EngineRunner(s Strategy, paths []NetWorthList) GoalRunner {
+	return func(amount float32, path int) float32 {
+		st := s
+		if s.BasicAmount > 0 {
+			st.MinAmount = s.MinAmount * amount / s.BasicAmount
+			st.MaxAmount = s.MaxAmount * amount / s.BasicAmount
+		} else {
+			st.MaxAmount = amount * 10
+		}
+		st.BasicAmount = amount
+		return NewEngine(st, paths[path]).Run().Value
+	}
+}
+
+//PackRunner 组合引擎的目标运行器，build按每期投入金额和路径创建组合
+func PackRunner(build func(amount float32, path int) *PackEngine) GoalRunner {
+	return func(amount float32, path int) float32 {
+		e := build(amount, path)
+		e.SetLogger(log.New(ioutil.Discard, "", 0))
+		return e.Run().Value
+	}
+}
+
+//SolveStrategies 对每组候选策略求解所需投入，按所需金额从低到高排序，达不到目标的排在最后
+func SolveStrategies(goal Goal, candidates []Strategy, paths []NetWorthList) []GoalResult {
+	results := make([]GoalResult, 0, len(candidates))
+	for _, s := range candidates {
+		s.EndDate = goal.Date
+		result := SolveGoal(goal, len(paths), EngineRunner(s, paths))
+		result.Strategy = s
+		results = append(results, result)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Reached != results[j].Reached {
+			return results[i].Reached
+		}
+		return results[i].Amount < results[j].Amount
+	})
+	return results
+}
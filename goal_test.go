@@ -0,0 +1,46 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolveGoal(t *testing.T) {
+	start := ParseDate("2020-01-01")
+	paths := HistoricalPaths(flatNws("2015-01-01", 2000, 1), start, 1, 6)
+	assert.Len(t, paths, 9)
+	assert.Equal(t, ParseDate("2015-01-01").Weekday(), paths[0][0].Date.Weekday())
+	assert.True(t, DiffDays(paths[0][0].Date, start) < 7)
+	s := Strategy{
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		StartDate:   start,
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		FixedMethod: FixedInvest,
+	}
+	goal := Goal{Target: 24000, Date: ParseDate("2020-12-31"), Confidence: 0.9}
+	results := SolveStrategies(goal, []Strategy{s}, paths)
+	assert.Len(t, results, 1)
+	assert.True(t, results[0].Reached)
+	//每月投入12次
+	assert.Equal(t, float32(2000), results[0].Amount)
+	assert.Equal(t, 1.0, results[0].Probability)
+
+	goal.MaxAmount = 1500
+	result := SolveGoal(goal, len(paths), EngineRunner(s, paths))
+	assert.False(t, result.Reached)
+}
+
+func TestSolveGoalProbability(t *testing.T) {
+	//第i条路径上资产价值为投入金额的i倍
+	run := func(amount float32, path int) float32 {
+		return amount * float32(path+1)
+	}
+	result := SolveGoal(Goal{Target: 10000, Confidence: 0.5}, 10, run)
+	assert.True(t, result.Reached)
+	assert.Equal(t, float32(1670), result.Amount)
+	assert.Equal(t, 0.5, result.Probability)
+}
@@ -0,0 +1,255 @@
+package backtesting_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	. "github.com/geekfund/backtesting"
+)
+
+var update = flag.Bool("update", false, "重新生成testdata/golden下的结果文件")
+
+type (
+	//goldenCase 回测用例，单只基金或组合
+	goldenCase struct {
+		Name string
+		Run  func() golden
+	}
+	//golden 回测结果快照
+	golden struct {
+		Invest    float32
+		Balance   float32
+		Value     float32
+		Shares    float32
+		Profit    float32
+		Withdrawn float32
+		XIRR      float64
+		Trans     []goldenTrans
+	}
+	goldenTrans struct {
+		Date      string
+		Code      string `json:",omitempty"`
+		TransType TransType
+		NAV       float32
+		Amount    float32
+		Shares    float32
+		TransFee  float32
+	}
+)
+
+//loadFixture 读取testdata/nav下的离线净值
+func loadFixture(name string) NetWorthList {
+	file, err := os.Open(filepath.Join("testdata", "nav", name+".csv"))
+	if err != nil {
+		log.Panic("读取净值失败", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		log.Panic("解析净值失败", err)
+	}
+	var nws NetWorthList
+	for _, row := range rows[1:] {
+		nws = append(nws, NetWorth{
+			Date:      ParseDate(row[0]),
+			NAV:       ParseFloat32(row[1]),
+			CNAV:      ParseFloat32(row[2]),
+			ROC:       ParseFloat32(row[3]),
+			Dividends: ParseFloat32(row[4]),
+			Splits:    ParseFloat32(row[5]),
+			Yield:     ParseFloat32(row[6]),
+			Yield7:    ParseFloat32(row[7]),
+		})
+	}
+	return nws
+}
+
+func goldenStrategy(code string) Strategy {
+	return Strategy{
+		Code:        code,
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		SellPoint:   30,
+		StartDate:   ParseDate("2017-01-01"),
+		EndDate:     ParseDate("2021-12-31"),
+		TransRate:   0.15,
+		SellRate:    0.5,
+		CycleType:   CycleMonth,
+		CycleValue:  15,
+		VolaDays:    120,
+		FixedMethod: FloatInvest,
+	}
+}
+
+func engineGolden(e *Engine) golden {
+	r := e.Run()
+	g := golden{
+		Invest:    r.Invest,
+		Balance:   r.Balance,
+		Value:     r.Value,
+		Shares:    r.Shares,
+		Profit:    r.Profit,
+		Withdrawn: r.Withdrawn,
+		XIRR:      r.XIRR(),
+	}
+	for _, t := range r.TransList {
+		g.Trans = append(g.Trans, goldenTrans{
+			Date:      DateToString(t.Date),
+			TransType: t.TransType,
+			NAV:       t.NAV,
+			Amount:    t.Amount,
+			Shares:    t.Shares,
+			TransFee:  t.TransFee,
+		})
+	}
+	return g
+}
+
+func packGolden(e *PackEngine) golden {
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	r := e.Run()
+	g := golden{
+		Invest:    r.Invest,
+		Balance:   r.Balance,
+		Value:     r.Value,
+		Profit:    r.Profit,
+		Withdrawn: r.Withdrawn,
+	}
+	var code = map[Transaction]string{}
+	for k, item := range r.Items {
+		for _, t := range item.TransList {
+			t.Decision = nil
+			code[t] = strconv.Itoa(k)
+		}
+	}
+	for _, t := range r.TransList {
+		t.Decision = nil
+		g.Trans = append(g.Trans, goldenTrans{
+			Date:      DateToString(t.Date),
+			Code:      code[t],
+			TransType: t.TransType,
+			NAV:       t.NAV,
+			Amount:    t.Amount,
+			Shares:    t.Shares,
+			TransFee:  t.TransFee,
+		})
+	}
+	return g
+}
+
+var goldenCases = []goldenCase{
+	{"engine_float", func() golden {
+		return engineGolden(NewEngine(goldenStrategy("equity"), loadFixture("equity")))
+	}},
+	{"engine_fixed", func() golden {
+		s := goldenStrategy("equity")
+		s.FixedMethod = FixedInvest
+		return engineGolden(NewEngine(s, loadFixture("equity")))
+	}},
+	{"engine_week", func() golden {
+		s := goldenStrategy("equity")
+		s.CycleType = CycleWeek
+		s.CycleValue = 3
+		s.BasicAmount, s.MaxAmount = 300, 3000
+		return engineGolden(NewEngine(s, loadFixture("equity")))
+	}},
+	{"engine_bond", func() golden {
+		s := goldenStrategy("bond")
+		s.SellPoint = 5
+		return engineGolden(NewEngine(s, loadFixture("bond")))
+	}},
+	{"engine_withdrawal", func() golden {
+		e := NewEngine(goldenStrategy("bond"), loadFixture("bond"))
+		e.SetWithdrawal(Withdrawal{Rule: WithdrawPercent, Initial: 500000, Rate: 4, Day: 10})
+		return engineGolden(e)
+	}},
+	{"pack", func() golden {
+		items := PackItemList{
+			{Engine: NewEngine(goldenStrategy("equity"), loadFixture("equity")), TOF: Radical, Precent: 50},
+			{Engine: NewEngine(goldenStrategy("bond"), loadFixture("bond")), TOF: Conservative, Precent: 30},
+			{Engine: NewEngine(goldenStrategy("money"), loadFixture("money")), TOF: Cash, Precent: 20},
+		}
+		e := NewPackEngine(items, ParseDate("2017-01-01"), 5000)
+		e.SetEndDate(ParseDate("2021-12-31"))
+		return packGolden(e)
+	}},
+}
+
+//TestGolden 对比回测结果与testdata/golden下保存的结果，使用 go test -run TestGolden -update 更新
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.Name, func(t *testing.T) {
+			got := c.Run()
+			file := filepath.Join("testdata", "golden", c.Name+".json")
+			if *update {
+				bts, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(file, append(bts, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			bts, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatalf("缺少golden文件，请使用 -update 生成: %v", err)
+			}
+			var want golden
+			if err := json.Unmarshal(bts, &want); err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range diffGolden(want, got) {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+//diffGolden 按容差比较结果，金额允许0.01的绝对误差或万分之一的相对误差
+func diffGolden(want, got golden) []string {
+	var diffs []string
+	near := func(a, b float64) bool {
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.IsNaN(a) && math.IsNaN(b)
+		}
+		return math.Abs(a-b) <= math.Max(0.01, math.Abs(a)*1e-4)
+	}
+	metric := func(name string, a, b float64) {
+		if !near(a, b) {
+			diffs = append(diffs, fmt.Sprintf("%s: 期望 %.4f 实际 %.4f", name, a, b))
+		}
+	}
+	metric("Invest", float64(want.Invest), float64(got.Invest))
+	metric("Balance", float64(want.Balance), float64(got.Balance))
+	metric("Value", float64(want.Value), float64(got.Value))
+	metric("Shares", float64(want.Shares), float64(got.Shares))
+	metric("Profit", float64(want.Profit), float64(got.Profit))
+	metric("Withdrawn", float64(want.Withdrawn), float64(got.Withdrawn))
+	metric("XIRR", want.XIRR, got.XIRR)
+	if len(want.Trans) != len(got.Trans) {
+		diffs = append(diffs, fmt.Sprintf("交易笔数: 期望 %d 实际 %d", len(want.Trans), len(got.Trans)))
+	}
+	for i := 0; i < len(want.Trans) && i < len(got.Trans) && len(diffs) < 20; i++ {
+		w, g := want.Trans[i], got.Trans[i]
+		if w.Date != g.Date || w.Code != g.Code || w.TransType != g.TransType ||
+			!near(float64(w.NAV), float64(g.NAV)) || !near(float64(w.Amount), float64(g.Amount)) ||
+			!near(float64(w.Shares), float64(g.Shares)) || !near(float64(w.TransFee), float64(g.TransFee)) {
+			diffs = append(diffs, fmt.Sprintf("第%d笔交易: 期望 %+v 实际 %+v", i+1, w, g))
+		}
+	}
+	return diffs
+}
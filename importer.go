@@ -0,0 +1,339 @@
+package backtesting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	//CSVMapping 交易记录CSV的列映射，列名为空表示没有该列
+	CSVMapping struct {
+		Comma      rune                 //分隔符，默认逗号
+		Skip       int                  //表头之前需要跳过的行数
+		Code       string               //基金代码列
+		Date       string               //确认日期列
+		Type       string               //交易类型列
+		Amount     string               //确认金额列
+		Shares     string               //确认份额列
+		NAV        string               //确认净值列
+		Fee        string               //手续费列
+		DateLayout string               //日期格式，默认2006-01-02
+		Types      map[string]TransType //交易类型名称映射，值为0的类型忽略，如撤单
+	}
+	//Lot 按先进先出计算的持仓批次
+	Lot struct {
+		Date   time.Time //买入日期
+		Shares float32   //剩余份额，已按拆分折算
+		Cost   float32   //剩余份额的买入成本，包含手续费
+		Value  float32   //当前价值
+		Days   int       //持有天数
+	}
+	//Holding 实盘持仓的表现
+	Holding struct {
+		Result
+		XIRR  float64 //年化收益率(%)
+		Lots  []Lot   //持仓批次
+		Flows []Flow  //投入的本金，不含使用卖出资金的买入
+	}
+)
+
+var (
+	//TianTianMapping 天天基金交易记录导出格式
+	TianTianMapping = CSVMapping{
+		Code:   "基金代码",
+		Date:   "确认日期",
+		Type:   "业务类型",
+		Amount: "确认金额",
+		Shares: "确认份额",
+		NAV:    "确认净值",
+		Fee:    "手续费",
+		Types: map[string]TransType{
+			"定投":    TransFixed,
+			"申购":    TransAppend,
+			"认购":    TransAppend,
+			"红利再投资": TransDividends,
+			"现金分红":  TransDividends,
+			"赎回":    TransSell,
+			"转换":    TransSwitch,
+			"撤单":    0,
+		},
+	}
+	//AlipayMapping 支付宝基金交易记录导出格式
+	AlipayMapping = CSVMapping{
+		Code:   "基金代码",
+		Date:   "确认日期",
+		Type:   "交易类型",
+		Amount: "确认金额",
+		Shares: "确认份额",
+		NAV:    "成交净值",
+		Fee:    "手续费",
+		Types: map[string]TransType{
+			"定投":   TransFixed,
+			"买入":   TransAppend,
+			"分红":   TransDividends,
+			"卖出":   TransSell,
+			"转换":   TransSwitch,
+			"交易关闭": 0,
+		},
+	}
+	//BankMapping 银行APP基金交易记录导出格式
+	BankMapping = CSVMapping{
+		Code:       "产品代码",
+		Date:       "交易日期",
+		Type:       "交易类型",
+		Amount:     "成交金额",
+		Shares:     "成交份额",
+		NAV:        "单位净值",
+		Fee:        "手续费",
+		DateLayout: "20060102",
+		Types: map[string]TransType{
+			"定期定额": TransFixed,
+			"申购":   TransAppend,
+			"认购":   TransAppend,
+			"分红":   TransDividends,
+			"赎回":   TransSell,
+			"转换":   TransSwitch,
+		},
+	}
+)
+
+//ImportCSV 按映射读取交易记录，返回按基金代码分组并按日期排序的交易记录。没有代码列时代码为空
+func ImportCSV(r io.Reader, m CSVMapping) (map[string]TransactionList, error) {
+	reader := csv.NewReader(r)
+	if m.Comma != 0 {
+		reader.Comma = m.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) <= m.Skip {
+		return nil, fmt.Errorf("缺少表头")
+	}
+	header := map[string]int{}
+	for k, name := range rows[m.Skip] {
+		header[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = k
+	}
+	for _, name := range []string{m.Date, m.Type} {
+		if _, ok := header[name]; !ok {
+			return nil, fmt.Errorf("缺少列: %s", name)
+		}
+	}
+	layout := m.DateLayout
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	items := map[string]TransactionList{}
+	for line, row := range rows[m.Skip+1:] {
+		cell := func(name string) string {
+			if k, ok := header[name]; ok && name != "" && k < len(row) {
+				return strings.TrimSpace(row[k])
+			}
+			return ""
+		}
+		if strings.Join(row, "") == "" {
+			continue
+		}
+		t, ok, err := m.parse(cell, layout)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", m.Skip+line+2, err)
+		}
+		if ok {
+			code := cell(m.Code)
+			items[code] = append(items[code], t)
+		}
+	}
+	for _, list := range items {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Date.Before(list[j].Date)
+		})
+	}
+	return items, nil
+}
+
+//parse 解析一行交易记录，忽略的交易类型返回false
+func (m CSVMapping) parse(cell func(name string) string, layout string) (Transaction, bool, error) {
+	name := cell(m.Type)
+	transType, ok := m.transType(name)
+	if !ok {
+		return Transaction{}, false, fmt.Errorf("未知的交易类型: %s", name)
+	}
+	if transType == 0 {
+		return Transaction{}, false, nil
+	}
+	date, err := time.Parse(layout, cell(m.Date))
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	t := Transaction{
+		Date:      date,
+		Amount:    parseNumber(cell(m.Amount)),
+		Shares:    parseNumber(cell(m.Shares)),
+		NAV:       parseNumber(cell(m.NAV)),
+		TransFee:  parseNumber(cell(m.Fee)),
+		TransType: transType,
+	}
+	//转出记录的份额和金额为负数，其余交易均为正数
+	if t.TransType == TransSwitch && (strings.Contains(name, "转出") || t.Shares < 0 || t.Amount < 0) {
+		t.Shares, t.Amount = -abs32(t.Shares), -abs32(t.Amount)
+	} else {
+		t.Shares, t.Amount = abs32(t.Shares), abs32(t.Amount)
+	}
+	t.TransFee = abs32(t.TransFee)
+	return t, true, nil
+}
+
+//transType 交易类型名称完全匹配优先，否则按包含关键字匹配，取最长的关键字
+func (m CSVMapping) transType(name string) (TransType, bool) {
+	if t, ok := m.Types[name]; ok {
+		return t, true
+	}
+	var key string
+	for k := range m.Types {
+		if strings.Contains(name, k) && len(k) > len(key) {
+			key = k
+		}
+	}
+	if key == "" {
+		return 0, false
+	}
+	return m.Types[key], true
+}
+
+//parseNumber 解析带千分位、单位的金额或份额，空值为0
+func parseNumber(s string) float32 {
+	s = strings.NewReplacer(",", "", "元", "", "份", "", "￥", "", "¥", "", " ", "").Replace(s)
+	if s == "" || s == "--" {
+		return 0
+	}
+	return ParseFloat32(s)
+}
+
+func abs32(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
+
+//Replay 按引擎相同的记账方式回放实盘交易，计算当前价值、年化收益率及持仓批次。
+//份额为0的分红视为现金分红，计入取出金额
+func Replay(trans TransactionList, nws NetWorthList) Holding {
+	ctx := NewEngine(Strategy{}, nws)
+	var lots []Lot
+	var flows []Flow
+	k := 0
+	for _, nw := range nws {
+		if k == 0 && (len(trans) == 0 || DiffDays(nw.Date, trans[0].Date) < 0) {
+			continue
+		}
+		if nw.Splits > 0 {
+			ctx.spilit(nw)
+			for i := range lots {
+				lots[i].Shares *= nw.Splits
+			}
+		}
+		//非交易日确认的交易在下一个净值日计入
+		for ; k < len(trans) && DiffDays(trans[k].Date, nw.Date) <= 0; k++ {
+			invest := ctx.invest
+			lots = ctx.replay(trans[k], nw, lots)
+			if ctx.invest > invest {
+				flows = append(flows, Flow{Date: trans[k].Date, Amount: ctx.invest - invest})
+			}
+		}
+		ctx.refresh(nw)
+	}
+	holding := Holding{Result: ctx.result(), Flows: flows}
+	cash := ctx.trans.CashFlows()
+	cash = append(cash, CashFlow{Date: ctx.date, Amount: float64(ctx.shares * ctx.nav)})
+	holding.XIRR = XIRR(cash)
+	for _, lot := range lots {
+		lot.Value = lot.Shares * ctx.nav
+		lot.Days = DiffDays(ctx.date, lot.Date)
+		holding.Lots = append(holding.Lots, lot)
+	}
+	return holding
+}
+
+//replay 计入一笔实盘交易，缺少的净值、份额和金额按当日净值补齐
+func (ctx *Engine) replay(t Transaction, nw NetWorth, lots []Lot) []Lot {
+	if t.NAV == 0 {
+		t.NAV = nw.NAV
+	}
+	switch t.TransType {
+	case TransFixed, TransAppend, TransContribute, TransDividends:
+		if t.TransType == TransDividends && t.Shares == 0 {
+			ctx.drawing.withdrawn += t.Amount
+			break
+		}
+		if t.Shares == 0 {
+			t.Shares = ParseFloat32(fmt.Sprintf("%.2f", (t.Amount-t.TransFee)/t.NAV))
+		}
+		if t.Amount == 0 {
+			t.Amount = t.Shares*t.NAV + t.TransFee
+		}
+		if t.TransType != TransDividends {
+			if ctx.balance > t.Amount {
+				ctx.balance -= t.Amount
+			} else {
+				ctx.invest += t.Amount
+			}
+		}
+		ctx.shares += t.Shares
+		lots = append(lots, Lot{Date: t.Date, Shares: t.Shares, Cost: t.Amount})
+	case TransSell, TransWithdraw:
+		if t.Shares == 0 {
+			t.Shares = t.Amount / t.NAV
+		}
+		if t.Amount == 0 {
+			t.Amount = t.Shares * t.NAV
+		}
+		ctx.shares -= t.Shares
+		if t.TransType == TransWithdraw {
+			ctx.drawing.withdrawn += t.Amount - t.TransFee
+		} else {
+			ctx.balance += t.Amount - t.TransFee
+		}
+		lots = consume(lots, t.Shares)
+	case TransSwitch:
+		if t.Shares == 0 {
+			t.Shares = t.Amount / t.NAV
+		}
+		if t.Amount == 0 {
+			t.Amount = t.Shares * t.NAV
+		}
+		ctx.shares += t.Shares
+		if t.Shares < 0 {
+			ctx.balance -= t.Amount
+			lots = consume(lots, -t.Shares)
+		} else {
+			if ctx.balance > t.Amount {
+				ctx.balance -= t.Amount
+			} else {
+				ctx.invest += t.Amount
+			}
+			lots = append(lots, Lot{Date: t.Date, Shares: t.Shares, Cost: t.Amount})
+		}
+	}
+	ctx.trans.Append(t)
+	return lots
+}
+
+//consume 按先进先出扣减持仓批次
+func consume(lots []Lot, shares float32) []Lot {
+	for len(lots) > 0 && shares > 0 {
+		lot := &lots[0]
+		if lot.Shares > shares {
+			lot.Cost -= lot.Cost / lot.Shares * shares
+			lot.Shares -= shares
+			return lots
+		}
+		shares -= lot.Shares
+		lots = lots[1:]
+	}
+	return lots
+}
@@ -0,0 +1,64 @@
+package backtesting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tiantianCSV = `基金代码,确认日期,业务类型,确认金额,确认份额,确认净值,手续费
+000001,2020-06-01,赎回,600.00,600.00,1.0000,0.00
+000001,2020-01-02,定投,"1,000.00",1000.00,1.0000,0.00
+000001,2020-02-01,申购,1000.00元,,,0.00
+000001,2020-03-01,撤单,1000.00,,,
+000002,2020-01-05,申购,500.00,500.00,1.0000,0.00
+`
+
+func TestImportCSV(t *testing.T) {
+	items, err := ImportCSV(strings.NewReader(tiantianCSV), TianTianMapping)
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	trans := items["000001"]
+	assert.Len(t, trans, 3)
+	assert.Equal(t, ParseDate("2020-01-02"), trans[0].Date)
+	assert.Equal(t, TransFixed, trans[0].TransType)
+	assert.Equal(t, float32(1000), trans[0].Amount)
+	assert.Equal(t, TransAppend, trans[1].TransType)
+	assert.Equal(t, TransSell, trans[2].TransType)
+
+	_, err = ImportCSV(strings.NewReader("确认日期,业务类型\n2020-01-02,强制调增\n"), TianTianMapping)
+	assert.Error(t, err)
+	_, err = ImportCSV(strings.NewReader("日期,类型\n"), TianTianMapping)
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	items, err := ImportCSV(strings.NewReader(tiantianCSV), TianTianMapping)
+	assert.NoError(t, err)
+	nws := flatNws("2020-01-01", 366, 1)
+	nws[200].Splits = 2
+	for i := 200; i < len(nws); i++ {
+		nws[i].NAV = 0.5
+	}
+	h := Replay(items["000001"], nws)
+	assert.Equal(t, float32(2000), h.Invest)
+	assert.Equal(t, float32(600), h.Balance)
+	assert.Equal(t, float32(2800), h.Shares)
+	assert.InDelta(t, 2000, h.Value, 0.01)
+	assert.InDelta(t, 0, h.XIRR, 0.01)
+	assert.Len(t, h.Lots, 2)
+	assert.Equal(t, float32(800), h.Lots[0].Shares)
+	assert.InDelta(t, 400, h.Lots[0].Cost, 0.01)
+	assert.Equal(t, 364, h.Lots[0].Days)
+}
+
+func TestReplayEngine(t *testing.T) {
+	nws := waveNws("2020-01-01", 1000)
+	result := NewEngine(waveStrategy("2020-01-01", "2022-12-31"), nws).Run()
+	h := Replay(result.TransList, nws.Window(ParseDate("2020-01-01"), result.Date))
+	assert.Equal(t, result.Invest, h.Invest)
+	assert.InDelta(t, result.Value, h.Value, 0.01)
+	assert.InDelta(t, result.Profit, h.Profit, 0.01)
+	assert.InDelta(t, result.XIRR(), h.XIRR, 0.01)
+}
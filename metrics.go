@@ -0,0 +1,163 @@
+package backtesting
+
+import (
+	"math"
+	"time"
+)
+
+//CashFlow 现金流，投入为负数，取回为正数
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+//CashFlows 交易产生的现金流。分红再投资不产生现金流，现金分红为取回
+func (items TransactionList) CashFlows() []CashFlow {
+	flows := make([]CashFlow, 0, len(items))
+	for _, t := range items {
+		var amount float64
+		switch t.TransType {
+		case TransFixed, TransAppend, TransContribute:
+			amount = -float64(t.Amount)
+		case TransSell, TransWithdraw:
+			amount = float64(t.Amount - t.TransFee)
+		case TransSwitch:
+			amount = -float64(t.Amount)
+		case TransDividends:
+			//份额为0的分红为现金分红
+			if t.Shares != 0 || t.Amount <= 0 {
+				continue
+			}
+			amount = float64(t.Amount)
+		default:
+			continue
+		}
+		flows = append(flows, CashFlow{Date: t.Date, Amount: amount})
+	}
+	return flows
+}
+
+//XIRR 不规则现金流的年化收益率(%)，无法求解时返回NaN
+func XIRR(flows []CashFlow) float64 {
+	if len(flows) < 2 {
+		return math.NaN()
+	}
+	first := flows[0].Date
+	for _, f := range flows {
+		if f.Date.Before(first) {
+			first = f.Date
+		}
+	}
+	npv := func(rate float64) float64 {
+		var v float64
+		for _, f := range flows {
+			v += f.Amount / math.Pow(1+rate, float64(DiffDays(f.Date, first))/365)
+		}
+		return v
+	}
+	//二分法求解，收益率范围-99.99%至10000%
+	lo, hi := -0.9999, 100.0
+	if npv(lo)*npv(hi) > 0 {
+		return math.NaN()
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2 * 100
+}
+
+//XIRR 回测结果的年化收益率(%)，期末持仓价值作为最后一笔现金流
+func (r Result) XIRR() float64 {
+	flows := r.TransList.CashFlows()
+	flows = append(flows, CashFlow{Date: r.Date, Amount: float64(r.Shares * r.Nav)})
+	return XIRR(flows)
+}
+
+//XIRR 组合的年化收益率(%)，按各项目的交易计算，期末持仓价值作为最后一笔现金流，不含组合余额
+func (r PackResult) XIRR() float64 {
+	flows := r.TransList.CashFlows()
+	flows = append(flows, CashFlow{Date: r.Date, Amount: float64(r.Value - r.Balance)})
+	return XIRR(flows)
+}
+
+//Window 截取日期区间内的净值，包含起止日期
+func (items NetWorthList) Window(start, end time.Time) NetWorthList {
+	var list NetWorthList
+	for _, nw := range items {
+		if DiffDays(nw.Date, start) >= 0 && DiffDays(nw.Date, end) <= 0 {
+			list = append(list, nw)
+		}
+	}
+	return list
+}
+
+//index 按日涨跌幅复利计算的净值指数，包含分红再投资
+func (items NetWorthList) index() []float64 {
+	idx := make([]float64, len(items))
+	v := 1.0
+	for i, nw := range items {
+		if i > 0 {
+			v *= 1 + float64(nw.ROC)/100
+		}
+		idx[i] = v
+	}
+	return idx
+}
+
+//CAGR 年化收益率(%)
+func (items NetWorthList) CAGR() float64 {
+	if len(items) < 2 {
+		return 0
+	}
+	idx := items.index()
+	days := DiffDays(items[len(items)-1].Date, items[0].Date)
+	if days <= 0 {
+		return 0
+	}
+	return (math.Pow(idx[len(idx)-1], 365/float64(days)) - 1) * 100
+}
+
+//MaxDrawdown 最大回撤(%)，为正数
+func (items NetWorthList) MaxDrawdown() float64 {
+	var peak, dd float64
+	for _, v := range items.index() {
+		if v > peak {
+			peak = v
+		}
+		if d := (peak - v) / peak * 100; d > dd {
+			dd = d
+		}
+	}
+	return dd
+}
+
+//Volatility 年化波动率(%)
+func (items NetWorthList) Volatility() float64 {
+	if len(items) < 3 {
+		return 0
+	}
+	rocs := make([]float64, 0, len(items)-1)
+	for _, nw := range items[1:] {
+		rocs = append(rocs, float64(nw.ROC))
+	}
+	mu := mean(rocs)
+	var v float64
+	for _, r := range rocs {
+		v += (r - mu) * (r - mu)
+	}
+	return math.Sqrt(v/float64(len(rocs)-1)) * math.Sqrt(252)
+}
+
+//Sharpe 夏普比率，riskFree为无风险年化收益率(%)
+func (items NetWorthList) Sharpe(riskFree float64) float64 {
+	vol := items.Volatility()
+	if vol == 0 {
+		return 0
+	}
+	return (items.CAGR() - riskFree) / vol
+}
@@ -0,0 +1,54 @@
+package backtesting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXIRR(t *testing.T) {
+	flows := []CashFlow{
+		{Date: ParseDate("2020-01-01"), Amount: -1000},
+		{Date: ParseDate("2020-12-31"), Amount: 1100},
+	}
+	assert.InDelta(t, 10, XIRR(flows), 0.01)
+	flows = []CashFlow{
+		{Date: ParseDate("2020-01-01"), Amount: -1000},
+		{Date: ParseDate("2020-07-01"), Amount: -1000},
+		{Date: ParseDate("2020-12-31"), Amount: 1900},
+	}
+	assert.True(t, XIRR(flows) < 0)
+	assert.True(t, math.IsNaN(XIRR(flows[:1])))
+}
+
+func TestResultXIRR(t *testing.T) {
+	var nws NetWorthList
+	date := ParseDate("2020-01-01")
+	for i := 0; i <= 365; i++ {
+		nav := float32(1 + 0.1*float64(i)/365)
+		nws = append(nws, NetWorth{Date: date.AddDate(0, 0, i), NAV: nav})
+	}
+	result := Result{
+		Date:   nws[365].Date,
+		Nav:    nws[365].NAV,
+		Shares: 1000,
+		TransList: TransactionList{
+			{Date: date, Amount: 1000, NAV: 1, Shares: 1000, TransType: TransFixed},
+		},
+	}
+	assert.InDelta(t, 10, result.XIRR(), 0.1)
+}
+
+func TestNetWorthMetrics(t *testing.T) {
+	var nws NetWorthList
+	date := ParseDate("2020-01-01")
+	for i, roc := range []float32{0, 10, -50, 20, 10} {
+		nws = append(nws, NetWorth{Date: date.AddDate(1*i, 0, 0), ROC: roc})
+	}
+	assert.InDelta(t, 50, nws.MaxDrawdown(), 1e-6)
+	//4年累计 1.1*0.5*1.2*1.1 = 0.726
+	assert.InDelta(t, (math.Pow(0.726, 365.0/1461)-1)*100, nws.CAGR(), 1e-6)
+	assert.True(t, nws.Volatility() > 0)
+	assert.Len(t, nws.Window(ParseDate("2021-01-01"), ParseDate("2022-01-01")), 2)
+}
@@ -1,6 +1,9 @@
 package backtesting
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 type (
 	//NetWorth 净值信息
@@ -12,22 +15,27 @@ type (
 		Dividends float32
 		Splits    float32
 		VolaRoc   float32 //周期内涨跌幅
+		Yield     float32 //货币基金每万份收益
+		Yield7    float32 //货币基金七日年化收益率(%)
 	}
 	//NetWorthList 净值列表
 	NetWorthList []NetWorth
 )
 
-//Today 获取指定当天的净值数据
+//Today 获取指定当天的净值数据。净值列表按日期升序排列，按日期二分查找
 func (items NetWorthList) Today(today time.Time) (k int, nw NetWorth) {
-	for k, nw := range items {
-		if today.Sub(nw.Date).Hours() < 24 && today.Sub(nw.Date).Hours() >= 0 {
-			return k, nw
-		}
+	from := today.Add(-24 * time.Hour)
+	k = sort.Search(len(items), func(i int) bool {
+		return items[i].Date.After(from)
+	})
+	if k < len(items) && !items[k].Date.After(today) {
+		return k, items[k]
 	}
 	return -1, nw
 }
 
-//InitVolaRoc 初始化周期内涨跌幅。避免循环内重复计算
+//InitVolaRoc 初始化周期内涨跌幅，为之前days个交易日的涨跌幅之和。避免循环内重复计算。
+//每日按float32重新累加窗口内的涨跌幅，与逐日计算的结果一致，耗时与历史长度成正比
 func (items NetWorthList) InitVolaRoc(days int) {
 	start := 0
 	for i := 0; i < len(items); i++ {
@@ -36,11 +44,9 @@ func (items NetWorthList) InitVolaRoc(days int) {
 		if i > days {
 			start = i - days
 		}
-		for _, t := range (items)[start:i] {
+		for _, t := range items[start:i] {
 			roc += t.ROC
 		}
-		nw := &items[i]
-		nw.VolaRoc = roc
+		items[i].VolaRoc = roc
 	}
-
 }
@@ -0,0 +1,39 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetWorthListToday(t *testing.T) {
+	nws := Synthetic{Start: ParseDate("2021-01-04"), Days: 20, Seed: 1}.Generate(GBM{Drift: 10, Vol: 20})
+	for k, nw := range nws {
+		i, today := nws.Today(nw.Date)
+		assert.Equal(t, k, i)
+		assert.Equal(t, nw.NAV, today.NAV)
+	}
+	//周末没有净值
+	k, _ := nws.Today(ParseDate("2021-01-09"))
+	assert.Equal(t, -1, k)
+	k, _ = nws.Today(ParseDate("2020-12-31"))
+	assert.Equal(t, -1, k)
+}
+
+func TestInitVolaRoc(t *testing.T) {
+	nws := Synthetic{Start: ParseDate("2016-01-04"), Days: 600, Seed: 7}.Generate(MeanRevert{Mean: 1.3, Speed: 1, Vol: 20})
+	nws.InitVolaRoc(120)
+	//与逐日重新累加窗口的结果一致
+	for i, nw := range nws {
+		start := 0
+		if i > 120 {
+			start = i - 120
+		}
+		var roc float64
+		for _, item := range nws[start:i] {
+			roc += float64(item.ROC)
+		}
+		assert.InDelta(t, roc, nw.VolaRoc, 1e-3)
+	}
+	assert.Equal(t, float32(0), nws[0].VolaRoc)
+}
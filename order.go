@@ -0,0 +1,110 @@
+package backtesting
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type (
+	//OrderRule 基金的交易限制
+	OrderRule struct {
+		MinBuy    float32 //最低申购金额
+		MinSell   float32 //最低赎回份额，持有份额不足时只能全部赎回
+		MinHold   float32 //最低保留份额，赎回后剩余不足时全部赎回
+		Precision int     //份额保留小数位数，为0时保留2位
+		Truncate  bool    //份额按位数截断，否则四舍五入
+	}
+	//Reject 被拒绝的委托
+	Reject struct {
+		Date      time.Time
+		TransType TransType
+		Amount    float32
+		Shares    float32
+		Reason    string
+	}
+)
+
+//roundShares 按份额精度处理买入份额
+func (r OrderRule) roundShares(shares float32) float32 {
+	p := math.Pow(10, float64(r.precision()))
+	if r.Truncate {
+		return float32(math.Floor(float64(shares)*p+1e-6) / p)
+	}
+	return ParseFloat32(fmt.Sprintf("%.*f", r.precision(), shares))
+}
+
+//enabled 是否设置了交易限制
+func (r OrderRule) enabled() bool {
+	return r != OrderRule{}
+}
+
+func (r OrderRule) precision() int {
+	if r.Precision <= 0 {
+		return 2
+	}
+	return r.Precision
+}
+
+//purchasable 按交易状态和交易限制调整申购金额，不满足时记录拒绝原因并返回0
+func (ctx *Engine) purchasable(amount float32, nw NetWorth, transType TransType) float32 {
+	if amount <= 0 {
+		return 0
+	}
+	if amount = ctx.subscribable(amount, nw, transType); amount <= 0 {
+		return 0
+	}
+	if rule := ctx.strategy.Order; amount < rule.MinBuy {
+		ctx.reject(nw, transType, amount, 0, fmt.Sprintf("申购金额低于最低申购金额%.2f", rule.MinBuy))
+		return 0
+	}
+	if ctx.exchange != nil {
+		return ctx.buyable(amount, nw, transType)
+	}
+	return amount
+}
+
+//redeemable 按交易限制调整赎回份额，不满足时记录拒绝原因并返回0
+func (ctx *Engine) redeemable(shares float32, nw NetWorth, transType TransType) float32 {
+	rule := ctx.strategy.Order
+	//设置了交易限制时赎回份额按精度截断，否则按原份额赎回
+	if rule.enabled() {
+		p := math.Pow(10, float64(rule.precision()))
+		shares = float32(math.Floor(float64(shares)*p+1e-6) / p)
+	}
+	if shares > ctx.shares {
+		shares = ctx.shares
+	}
+	if shares <= 0 {
+		return 0
+	}
+	if ctx.avail.At(nw.Date).Redeem == StatusSuspended {
+		ctx.reject(nw, transType, shares*nw.NAV, shares, "暂停赎回")
+		return 0
+	}
+	if shares < rule.MinSell {
+		if ctx.shares > rule.MinSell {
+			ctx.reject(nw, transType, shares*nw.NAV, shares, fmt.Sprintf("赎回份额低于最低赎回份额%.2f", rule.MinSell))
+			return 0
+		}
+		shares = ctx.shares
+	}
+	if left := ctx.shares - shares; left > 0 && left < rule.MinHold {
+		shares = ctx.shares
+	}
+	if ctx.exchange != nil {
+		return ctx.sellable(shares, nw, transType)
+	}
+	return shares
+}
+
+func (ctx *Engine) reject(nw NetWorth, transType TransType, amount, shares float32, reason string) {
+	ctx.skip(nw, transType, reason)
+	ctx.rejects = append(ctx.rejects, Reject{
+		Date:      nw.Date,
+		TransType: transType,
+		Amount:    amount,
+		Shares:    shares,
+		Reason:    reason,
+	})
+}
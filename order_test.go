@@ -0,0 +1,80 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderRuleRoundShares(t *testing.T) {
+	assert.Equal(t, float32(12.35), OrderRule{}.roundShares(12.345678))
+	assert.Equal(t, float32(12.34), OrderRule{Truncate: true}.roundShares(12.345678))
+	assert.Equal(t, float32(12.3), OrderRule{Precision: 1, Truncate: true}.roundShares(12.39))
+}
+
+func TestEngineRedeemable(t *testing.T) {
+	nw := NetWorth{Date: ParseDate("2020-01-02"), NAV: 1}
+	e := NewEngine(Strategy{Order: OrderRule{MinSell: 10, MinHold: 100}}, nil)
+	e.shares = 500
+	assert.Equal(t, float32(0), e.redeemable(5, nw, TransSell))
+	assert.Len(t, e.rejects, 1)
+	assert.Equal(t, float32(50.12), e.redeemable(50.129, nw, TransSell))
+	//剩余份额低于最低保留份额时全部赎回
+	assert.Equal(t, float32(500), e.redeemable(450, nw, TransSell))
+	assert.Equal(t, float32(500), e.redeemable(800, nw, TransSell))
+	//持有份额低于最低赎回份额时只能全部赎回
+	e.shares = 8
+	assert.Equal(t, float32(8), e.redeemable(5, nw, TransSell))
+	assert.Len(t, e.rejects, 1)
+
+	//未设置交易限制时不处理份额精度
+	e = NewEngine(Strategy{}, nil)
+	e.shares = 500
+	assert.Equal(t, float32(50.129), e.redeemable(50.129, nw, TransSell))
+}
+
+func TestEngineOrderRule(t *testing.T) {
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	s.MinAmount = 10
+	s.Order = OrderRule{MinBuy: 1000, Truncate: true}
+	result := NewEngine(s, waveNws("2020-01-01", 1000)).Run()
+	assert.NotEmpty(t, result.Rejects)
+	var invest float32
+	for _, tr := range result.TransList {
+		switch tr.TransType {
+		case TransFixed, TransAppend:
+			assert.GreaterOrEqual(t, tr.Amount, float32(1000))
+			invest += tr.Amount
+		}
+	}
+	assert.LessOrEqual(t, result.Invest, invest)
+	for _, r := range result.Rejects {
+		assert.Less(t, r.Amount, float32(1000))
+		assert.NotEmpty(t, r.Reason)
+	}
+}
+
+func TestPackEngineOrderRule(t *testing.T) {
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	s.Order = OrderRule{MinBuy: 5000, MinSell: 100000}
+	items := PackItemList{
+		{Engine: NewEngine(s, waveNws("2020-01-01", 1000)), TOF: Radical, Precent: 50},
+		{Engine: NewEngine(s, flatNws("2020-01-01", 1000, 1)), TOF: Conservative, Precent: 50},
+	}
+	e := NewPackEngine(items, ParseDate("2020-01-01"), 2000)
+	e.SetEndDate(ParseDate("2021-12-31"))
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	result := e.Run()
+	var rejects int
+	for _, item := range result.Items {
+		rejects += len(item.Rejects)
+	}
+	assert.NotZero(t, rejects)
+	for _, tr := range result.TransList {
+		if tr.TransType == TransFixed || tr.TransType == TransAppend {
+			assert.GreaterOrEqual(t, tr.Amount, float32(5000))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package backtesting
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"time"
@@ -9,23 +10,62 @@ import (
 type (
 	//PackEngine 组合引擎
 	PackEngine struct {
-		startDate time.Time
-		amount    float32         //定额投入
-		uprate    float32         //每年投入增长
-		items     PackItemList    //投资项目
-		month     int             //月份
-		balance   float32         //余额
-		value     float32         //持仓价值
-		invest    float32         //总投入
-		now       time.Time       //当前计算日期
-		trans     TransactionList //交易列表
+		startDate  time.Time
+		amount     float32         //定额投入
+		schedule   Schedule        //投入计划
+		flowed     time.Time       //一次性投入已计算到的日期
+		items      PackItemList    //投资项目
+		month      int             //月份
+		balance    float32         //余额
+		value      float32         //持仓价值
+		invest     float32         //总投入
+		now        time.Time       //当前计算日期
+		trans      TransactionList //交易列表
+		alloc      *Allocation     //动态配置模型
+		reweighted time.Time       //最近一次计算权重的日期
+		switchConf *SwitchConfig   //基金转换参数
+		switching  []switching     //在途的转换
+		endDate    time.Time       //截止日期，为零值时运行到当前日期
+		withdraw   *Withdrawal     //取出计划
+		drawing    withdrawing     //取出状态
+		logger     *log.Logger     //交易日志，为nil时使用标准日志
+		listing    int             //已成立的项目数，变化时重新分配投入金额
+		kept       kept            //激进项目预留金额的缓存
+	}
+	//keeping 影响激进项目预留金额的状态
+	keeping struct {
+		now     time.Time
+		trans   int
+		balance float32
+		cash    float32
+	}
+	//kept 激进项目预留金额的缓存
+	kept struct {
+		keeping
+		amount float32
+		ok     bool
+	}
+	//PackResult 组合运行结果
+	PackResult struct {
+		Date      time.Time
+		Invest    float32
+		Balance   float32
+		Value     float32
+		Profit    float32
+		Rop       float32
+		Withdrawn float32   //累计取出金额
+		Depleted  time.Time //资金耗尽日期，未耗尽时为零值
+		Items     []Result  //各项目的运行结果
+		TransList TransactionList
+		Err       error //项目的投资周期配置错误，不为nil时未进行交易
 	}
 	//PackItem 组合引擎
 	PackItem struct {
 		*Engine
-		TOF     TOF //资金类型 资金利用优先级依次为 激进 保守 现金
-		Precent int //分配比例，当激进类型不在需要投入时。激进类型比例将被分配到保守和现金中
-		Result  Result
+		TOF      TOF    //资金类型 资金利用优先级依次为 激进 保守 现金
+		Precent  int    //分配比例，当激进类型不在需要投入时。激进类型比例将被分配到保守和现金中
+		Fallback string //限购或暂停申购时未买入部分转投的项目代码
+		Result   Result
 	}
 	//PackItemList 项目列表
 	PackItemList []PackItem
@@ -62,22 +102,63 @@ func NewPackEngine(items PackItemList, startDate time.Time, amount float32) *Pac
 		items:     items,
 		startDate: startDate,
 		month:     int(startDate.Month()),
+		schedule: Schedule{
+			Amount:    amount,
+			StartDate: startDate,
+		},
 	}
-	for _, item := range items {
-		item.strategy.BasicAmount = amount * float32(item.Precent) / 100
-		item.strategy.MaxAmount = item.strategy.BasicAmount * 10
-	}
+	e.allot()
 	return e
 }
 
-//Run 运行组合
-func (e *PackEngine) Run() {
-	days := int(math.Ceil(time.Now().Sub(e.startDate).Hours() / 24))
+//SetAllocation 设置动态配置模型，按周期根据历史数据重新计算各项目的分配比例
+func (e *PackEngine) SetAllocation(alloc Allocation) {
+	e.alloc = &alloc
+}
+
+//SetEndDate 设置组合运行的截止日期
+func (e *PackEngine) SetEndDate(end time.Time) {
+	e.endDate = end
+}
+
+//Run 运行组合。非现金项目的投资周期配置错误时记录日志，不进行交易
+func (e *PackEngine) Run() PackResult {
+	for k, item := range e.items {
+		//现金类只通过归集买入和赎回，不按投资周期交易
+		if item.TOF == Cash {
+			continue
+		}
+		if err := item.strategy.Validate(); err != nil {
+			e.logf("项目%s的投资周期配置错误，不进行交易: %v", item.strategy.Code, err)
+			r := e.result()
+			r.Items[k].Err = err
+			e.items[k].Result.Err = err
+			r.Err = fmt.Errorf("项目%s: %v", item.strategy.Code, err)
+			return r
+		}
+	}
+	end := time.Now()
+	if !e.endDate.IsZero() {
+		end = e.endDate.AddDate(0, 0, 1)
+	}
+	days := int(math.Ceil(end.Sub(e.startDate).Hours() / 24))
 	for i := 0; i < days; i++ {
 		now := e.startDate.AddDate(0, 0, i)
 		e.now = now
-		if e.investDay(now) {
-			e.append()
+		//调仓、投入及取出只在交易日进行，休市日顺延到下一个交易日
+		if IsTradingDay(now) {
+			if e.listed() != e.listing {
+				e.allot()
+			}
+			if e.isReweightDay(now) {
+				e.reweight()
+			}
+			if e.withdraw != nil {
+				e.decumulate()
+			} else if e.investDay(now) {
+				e.append()
+			}
+			e.contribute(now)
 		}
 		e.refresh()
 		for k := range e.items {
@@ -87,28 +168,18 @@ func (e *PackEngine) Run() {
 			if k < 0 {
 				continue
 			}
+			e.confirm(item, nw)
 			trans := e.transaction(item, nw)
 			if trans == nil {
 				continue
 			}
-			e.trans.Append(*trans)
-			bm := map[int]string{1: "买入", 2: "分红", 3: "追加", 4: "卖出"}
-			log.Printf("%s %s %s 净值=%.4f 金额=%.2f 份额=%.2f 手续费=%.2f 持仓份额=%.2f 现金=%.2f",
-				DateToString(trans.Date),
-				item.strategy.Code,
-				bm[int(trans.TransType)],
-				trans.NAV,
-				trans.Amount,
-				trans.Shares,
-				trans.TransFee,
-				item.shares,
-				e.cashValue(),
-			)
+			e.record(item, trans)
 		}
+		e.sweep()
 	}
 
 	for _, item := range e.items {
-		log.Printf("投资结果 %s %s 净值=%.4f 本金=%.2f 余额=%.2f 价值=%.2f 份额=%.2f 利润=%.2f 收益率=%.2f",
+		e.logf("投资结果 %s %s 净值=%.4f 本金=%.2f 余额=%.2f 价值=%.2f 份额=%.2f 利润=%.2f 收益率=%.2f",
 			DateToString(item.date),
 			item.strategy.Code,
 			item.nav,
@@ -131,7 +202,7 @@ func (e *PackEngine) Run() {
 		// 	}
 		// }
 	}
-	log.Printf("投资结果 %s 本金=%.2f 余额=%.2f 价值=%.2f 利润=%.2f 收益率=%.2f",
+	e.logf("投资结果 %s 本金=%.2f 余额=%.2f 价值=%.2f 利润=%.2f 收益率=%.2f",
 		DateToString(e.now),
 		e.invest,
 		e.balance,
@@ -139,10 +210,63 @@ func (e *PackEngine) Run() {
 		e.value-e.invest,
 		(e.value-e.invest)/e.invest*100,
 	)
+	return e.result()
+}
+
+func (e *PackEngine) result() PackResult {
+	e.refresh()
+	items := make([]Result, len(e.items))
+	for k, item := range e.items {
+		items[k] = item.result()
+		e.items[k].Result = items[k]
+	}
+	profit := e.value + e.drawing.withdrawn - e.invest
+	return PackResult{
+		Date:      e.now,
+		Invest:    e.invest,
+		Balance:   e.balance,
+		Value:     e.value,
+		Profit:    profit,
+		Rop:       profit / e.invest * 100,
+		Withdrawn: e.drawing.withdrawn,
+		Depleted:  e.drawing.depleted,
+		Items:     items,
+		TransList: e.trans,
+	}
+}
+
+//SetLogger 设置交易日志输出
+func (e *PackEngine) SetLogger(logger *log.Logger) {
+	e.logger = logger
+}
+
+func (e *PackEngine) logf(format string, v ...interface{}) {
+	if e.logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+	e.logger.Printf(format, v...)
+}
+
+//record 记录组合交易
+func (e *PackEngine) record(item *PackItem, trans *Transaction) {
+	e.trans.Append(*trans)
+	bm := map[int]string{1: "买入", 2: "分红", 3: "追加", 4: "卖出", 5: "转换", 6: "投入", 7: "取出"}
+	e.logf("%s %s %s 净值=%.4f 金额=%.2f 份额=%.2f 手续费=%.2f 持仓份额=%.2f 现金=%.2f",
+		DateToString(trans.Date),
+		item.strategy.Code,
+		bm[int(trans.TransType)],
+		trans.NAV,
+		trans.Amount,
+		trans.Shares,
+		trans.TransFee,
+		item.shares,
+		e.cashValue(),
+	)
 }
 
 func (e *PackEngine) refresh() {
-	e.value = e.balance
+	e.value = e.balance + e.transit()
 	for _, item := range e.items {
 		e.value += item.value - item.balance
 		if item.balance > 0 {
@@ -158,15 +282,17 @@ func (e *PackEngine) AddBanlance(banlance float32) {
 }
 
 func (e *PackEngine) append() {
-	//每月投入金额到现金
-	e.balance += e.amount
-	e.invest += e.amount
+	//每月投入金额到现金，金额按投入计划增长或暂停
+	amount := e.schedule.AmountAt(e.now)
+	if amount > 0 && amount != e.amount {
+		e.amount = amount
+		e.allot()
+	}
+	e.balance += amount
+	e.invest += amount
 	e.month++
 	if e.month > 12 {
 		e.month = 1
-		if e.uprate > 0 {
-			e.amount *= 1.1
-		}
 	}
 }
 
@@ -176,13 +302,19 @@ func (e *PackEngine) investDay(now time.Time) bool {
 
 func (e *PackEngine) transaction(item *PackItem, nw NetWorth) *Transaction {
 	defer item.refresh(nw)
+	item.begin(nw)
 	var trans *Transaction
 	if nw.Splits > 0 {
 		item.spilit(nw)
 	}
+	item.serve(nw)
+	item.accrue(nw)
 	if nw.Dividends > 0 {
 		trans = item.dividends(nw)
 		return trans
+	} else if item.TOF == Cash {
+		//现金类只通过归集买入和赎回
+		return nil
 	} else if e.isSellDay(item, nw) {
 		shares := e.recoSell(item, nw)
 		if shares > item.shares {
@@ -192,7 +324,10 @@ func (e *PackEngine) transaction(item *PackItem, nw NetWorth) *Transaction {
 			return nil
 		}
 		trans = item.sell(shares, nw)
-		e.balance += trans.Amount
+		if trans == nil {
+			return nil
+		}
+		e.balance += trans.Amount - trans.TransFee
 		//TODO: 组合卖出将减少项目成本，非组合计算是增加余额，而组合是将余额存入总账
 		// item.invest -= trans.Amount
 		// item.balance = 0
@@ -203,12 +338,29 @@ func (e *PackEngine) transaction(item *PackItem, nw NetWorth) *Transaction {
 	if isBuyDay == false && isAppendDay == false {
 		return nil
 	}
+	transType := TransFixed
+	if !isBuyDay {
+		transType = TransAppend
+	}
 	//买入多少，取决于资金类型
 	amount := e.recoAmount(item, nw)
+	if amount <= 0 {
+		item.skip(nw, transType, "建议金额为0")
+		return nil
+	}
+	if amount > e.balance && item.TOF == Radical {
+		//余额不足时，从保守型转换补足
+		amount -= e.switchFor(item, amount-e.balance)
+	}
 	if amount > e.balance {
-		amount = e.balance
+		//余额不足时，从现金类赎回
+		e.redeem(amount - e.balance)
+	}
+	if amount > e.balance {
+		amount = item.step("按组合余额", e.balance)
 	}
 	if amount <= 0 {
+		item.skip(nw, transType, "组合余额不足")
 		return nil
 	}
 	if isBuyDay {
@@ -216,8 +368,13 @@ func (e *PackEngine) transaction(item *PackItem, nw NetWorth) *Transaction {
 	} else if isAppendDay {
 		trans = item.append(amount, nw)
 	}
+	if trans == nil {
+		e.redirect(item, amount)
+		return nil
+	}
 	//买入减去账户余额
 	e.balance -= trans.Amount
+	e.redirect(item, amount-trans.Amount)
 	return trans
 }
 
@@ -228,37 +385,37 @@ func (e *PackEngine) recoAmount(item *PackItem, nw NetWorth) float32 {
 		if amount <= item.strategy.MinAmount {
 			return amount
 		}
-		//如果最近3个月内有卖出，就不在买入
-		t := item.trans.LastSell()
-		if t != nil && t.Date.AddDate(0, 3, 0).Sub(e.now).Hours() > 0 {
+		//如果最近3个月内有卖出，就不在买入，间隔为卖出后3个月内的交易日数
+		t := item.lastTrans(TransSell)
+		if t != nil && item.cooldown(t.Date, e.now, TradingDaysBetween(t.Date, t.Date.AddDate(0, 3, 0))) {
 			return 0
 		}
 		// return amount
-		cash := e.cashValue()
+		cash := item.step("保守及现金价值", e.cashValue())
 		//当前比例+预留资金比例
-		pc := float32(item.Precent/100) + (1.0 - float32(e.radicalCount()))
+		pc := item.step("配置比例+预留比例", float32(item.Precent/100)+(1.0-float32(e.radicalCount())))
 		// pc := float32(item.Precent / 100)
 		// amount = cash * pc
 		//如果进攻型的下跌超过20%
 		if nw.VolaRoc < -25 {
-			amount = cash * pc
+			amount = item.step("周期跌幅<-25 现金*比例", cash*pc)
 			// log.Printf("跌幅超30的机会？涨跌幅=%.2f 余额=%.2f 建议买入=%.2f 保守价值=%.2f", nw.VolaRoc, e.balance, amount, cash)
 		} else if nw.VolaRoc < -20 {
-			amount = amount*3 + cash*0.8*pc
+			amount = item.step("周期跌幅<-20 金额*3+现金*0.8*比例", amount*3+cash*0.8*pc)
 			// log.Printf("跌幅超20的机会？涨跌幅=%.2f 余额=%.2f 建议买入=%.2f 保守价值=%.2f", nw.VolaRoc, e.balance, amount, cash)
 		} else if nw.VolaRoc < -15 {
 			// log.Println("跌幅超15的机会？", nw.VolaRoc, e.balance)
-			amount = amount*2 + cash*0.6*pc
+			amount = item.step("周期跌幅<-15 金额*2+现金*0.6*比例", amount*2+cash*0.6*pc)
 		} else if nw.VolaRoc < -10 {
 			// log.Println("跌幅超10的机会？", nw.VolaRoc,cash)
-			amount = amount*1.5 + cash*0.4*pc
+			amount = item.step("周期跌幅<-10 金额*1.5+现金*0.4*比例", amount*1.5+cash*0.4*pc)
 		} else if nw.VolaRoc < 0 {
 			// log.Println("跌幅超10的机会？", nw.VolaRoc,cash)
-			amount = amount + cash*0.2*pc
+			amount = item.step("周期跌幅<0 金额+现金*0.2*比例", amount+cash*0.2*pc)
 		} else {
-			amount = amount + cash*0.1*pc
+			amount = item.step("金额+现金*0.1*比例", amount+cash*0.1*pc)
 		}
-		return float32(int(math.Ceil(float64(amount/100))) * 100)
+		return item.step("向上取整到100元", float32(int(math.Ceil(float64(amount/100)))*100))
 	}
 
 	//如果一个月内有交易，则不在交易
@@ -267,30 +424,59 @@ func (e *PackEngine) recoAmount(item *PackItem, nw NetWorth) float32 {
 		return 0
 	}
 	//判断买入时，验证三个月内是否有卖出过。如果有，则不在买入
-	sell := item.trans.LastSell()
-	if sell != nil && sell.Date.AddDate(0, 3, 0).Sub(e.now).Hours() > 0 {
+	sell := item.lastTrans(TransSell)
+	if sell != nil && item.cooldown(sell.Date, e.now, TradingDaysBetween(sell.Date, sell.Date.AddDate(0, 3, 0))) {
 		return 0
 	}
 	amount = 0
-	if e.balance > e.keepBalance() {
-		amount = e.balance - e.keepBalance()
+	if e.liquid() > e.keepBalance() {
+		amount = e.liquid() - e.keepBalance()
 	}
-	return amount
+	return item.step("现金-激进预留", amount)
 }
 
 //获取激进保留s的金额
 func (e *PackEngine) keepBalance() float32 {
+	//同一日交易、余额及保守资金均未变化时沿用上次的结果
+	key := keeping{now: e.now, trans: len(e.trans), balance: e.balance, cash: e.cashValue()}
+	if e.kept.ok && e.kept.keeping == key {
+		return e.kept.amount
+	}
 	var amount float32
 	for _, v := range e.items {
 		if v.TOF != Radical {
 			continue
 		}
+		if !v.listed(e.now) {
+			continue
+		}
+		//在引擎的副本上试算，不记录到该项目当日的决策
+		probe := *v.Engine
+		probe.decision = nil
+		v.Engine = &probe
 		_, nw := v.nws.Today(e.now)
 		amount += e.recoAmount(&v, nw)
 	}
+	e.kept = kept{keeping: key, amount: amount, ok: true}
 	return amount
 }
 
+//listed 已成立的项目数
+func (e *PackEngine) listed() int {
+	var count int
+	for _, item := range e.items {
+		if item.listed(e.now) {
+			count++
+		}
+	}
+	return count
+}
+
+//listed 项目在指定日期是否已有净值，成立晚于组合开始日期的项目成立前不参与分配
+func (item PackItem) listed(now time.Time) bool {
+	return len(item.nws) > 0 && DiffDays(item.nws[0].Date, now) <= 0
+}
+
 //获取激进保留s的金额
 func (e *PackEngine) cashValue() float32 {
 	var amount float32
@@ -300,7 +486,7 @@ func (e *PackEngine) cashValue() float32 {
 		}
 		amount += v.value
 	}
-	return amount + e.balance
+	return amount + e.liquid()
 }
 
 //获取进攻配置比例
@@ -316,12 +502,15 @@ func (e *PackEngine) radicalCount() float32 {
 
 //是否卖出
 func (e *PackEngine) isSellDay(item *PackItem, nw NetWorth) bool {
+	if item.strategy.Disabled(RuleSell) {
+		return false
+	}
 	if item.TOF == Radical {
 		return item.isSellDay(nw)
 	}
 	//保守型的，就得留资金给进攻型
 	keep := e.keepBalance()
-	if keep > e.balance*1.2 {
+	if keep > e.liquid()*1.2 {
 		return true
 	}
 	return item.isSellDay(nw)
@@ -334,7 +523,7 @@ func (e *PackEngine) recoSell(item *PackItem, nw NetWorth) float32 {
 	}
 	//保守型的，就得留资金给进攻型
 	keep := e.keepBalance()
-	if e.balance < keep {
+	if e.liquid() < keep {
 		// log.Println("余额不足，需要卖出给进攻", e.balance, keep)
 		return keep * 1.2 / nw.NAV
 	}
@@ -0,0 +1,121 @@
+package backtesting
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	//Frequency 重采样频率
+	Frequency int
+	//Join 多个净值列表的对齐方式
+	Join int
+)
+
+const (
+	//FreqWeek 每周
+	FreqWeek Frequency = 1
+	//FreqMonth 每月
+	FreqMonth Frequency = 2
+)
+
+const (
+	//JoinInner 只保留所有列表都有净值的日期
+	JoinInner Join = 1
+	//JoinOuter 保留任一列表有净值的日期，缺失的净值沿用上一日，成立之前的日期不补
+	JoinOuter Join = 2
+)
+
+//key 日期所在周期的标识
+func (f Frequency) key(date time.Time) int {
+	if f == FreqWeek {
+		year, week := date.ISOWeek()
+		return year*100 + week
+	}
+	return date.Year()*100 + int(date.Month())
+}
+
+//Resample 按周或按月重采样，取周期内最后一个交易日的净值。
+//涨跌幅为周期内复利涨跌幅，分红合计，拆分倍数相乘，货币基金的万份收益合计
+func (items NetWorthList) Resample(f Frequency) NetWorthList {
+	var list NetWorthList
+	var growth float64
+	for k, nw := range items {
+		if k == 0 || f.key(nw.Date) != f.key(items[k-1].Date) {
+			growth = 1
+			list = append(list, NetWorth{})
+		}
+		last := &list[len(list)-1]
+		growth *= 1 + float64(nw.ROC)/100
+		dividends, yield, splits := last.Dividends+nw.Dividends, last.Yield+nw.Yield, last.Splits
+		if nw.Splits > 0 {
+			if splits == 0 {
+				splits = 1
+			}
+			splits *= nw.Splits
+		}
+		*last = nw
+		last.ROC = float32((growth - 1) * 100)
+		last.Dividends, last.Yield, last.Splits = dividends, yield, splits
+		last.VolaRoc = 0
+	}
+	return list
+}
+
+//Align 将多个净值列表对齐到相同的日期。内连接跳过的日期，涨跌幅、分红及拆分并入下一个保留的日期；
+//外连接补齐的日期沿用上一日净值，涨跌幅为0，列表成立之前的日期不补
+func Align(lists []NetWorthList, join Join) []NetWorthList {
+	counts := map[string]int{}
+	for _, nws := range lists {
+		for _, nw := range nws {
+			counts[DateToString(nw.Date)]++
+		}
+	}
+	var keys []string
+	for key, c := range counts {
+		if join == JoinOuter || c == len(lists) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	items := make([]NetWorthList, len(lists))
+	for k, nws := range lists {
+		var list NetWorthList
+		var merged NetWorth
+		growth, j := 1.0, 0
+		for _, key := range keys {
+			date := ParseDate(key)
+			found := false
+			for ; j < len(nws) && DiffDays(nws[j].Date, date) <= 0; j++ {
+				nw := nws[j]
+				growth *= 1 + float64(nw.ROC)/100
+				splits := merged.Splits
+				if nw.Splits > 0 {
+					if splits == 0 {
+						splits = 1
+					}
+					splits *= nw.Splits
+				}
+				nw.Dividends, nw.Yield, nw.Splits = merged.Dividends+nw.Dividends, merged.Yield+nw.Yield, splits
+				merged = nw
+				found = DiffDays(nw.Date, date) == 0
+			}
+			if found {
+				if len(list) > 0 {
+					merged.ROC = float32((growth - 1) * 100)
+				}
+				list = append(list, merged)
+				merged, growth = NetWorth{}, 1
+				continue
+			}
+			if n := len(list); n > 0 && join == JoinOuter {
+				fill := list[n-1]
+				fill.Date = date
+				fill.ROC, fill.Dividends, fill.Splits, fill.Yield = 0, 0, 0, 0
+				list = append(list, fill)
+			}
+		}
+		items[k] = list
+	}
+	return items
+}
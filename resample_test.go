@@ -0,0 +1,101 @@
+package backtesting
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResample(t *testing.T) {
+	nws := Synthetic{Start: ParseDate("2021-01-04"), Days: 60, Seed: 1, Events: []Event{{Day: 2, Dividends: 0.01}, {Day: 3, Dividends: 0.02}, {Day: 8, Splits: 2}}}.Generate(GBM{Drift: 10, Vol: 20})
+	weeks := nws.Resample(FreqWeek)
+	assert.Len(t, weeks, 12)
+	assert.Equal(t, "2021-01-08", DateToString(weeks[0].Date))
+	assert.Equal(t, nws[4].NAV, weeks[0].NAV)
+	assert.InDelta(t, 0.03, weeks[0].Dividends, 0.0001)
+	assert.Equal(t, float32(2), weeks[1].Splits)
+	//周涨跌幅为日涨跌幅复利
+	growth := float32(1)
+	for _, nw := range nws[5:10] {
+		growth *= 1 + nw.ROC/100
+	}
+	assert.InDelta(t, (growth-1)*100, weeks[1].ROC, 0.001)
+
+	months := nws.Resample(FreqMonth)
+	assert.Len(t, months, 3)
+	assert.Equal(t, "2021-01-29", DateToString(months[0].Date))
+	assert.Equal(t, nws[len(nws)-1].NAV, months[2].NAV)
+	assert.InDelta(t, (months[1].CNAV/months[0].CNAV-1)*100, months[1].ROC, 0.001)
+}
+
+func TestAlign(t *testing.T) {
+	a := NetWorthList{
+		{Date: ParseDate("2021-01-04"), NAV: 1, ROC: 0},
+		{Date: ParseDate("2021-01-05"), NAV: 1.1, ROC: 10},
+		{Date: ParseDate("2021-01-06"), NAV: 1.21, ROC: 10},
+		{Date: ParseDate("2021-01-08"), NAV: 1.331, ROC: 10, Dividends: 0.1},
+	}
+	//成立较晚的基金
+	b := NetWorthList{
+		{Date: ParseDate("2021-01-05"), NAV: 2},
+		{Date: ParseDate("2021-01-07"), NAV: 2.2, ROC: 10},
+		{Date: ParseDate("2021-01-08"), NAV: 2.2, ROC: 0},
+	}
+	inner := Align([]NetWorthList{a, b}, JoinInner)
+	assert.Len(t, inner[0], 2)
+	assert.Len(t, inner[1], 2)
+	assert.Equal(t, "2021-01-08", DateToString(inner[0][1].Date))
+	//跳过的日期涨跌幅及分红并入
+	assert.InDelta(t, 21, inner[0][1].ROC, 0.001)
+	assert.Equal(t, float32(0.1), inner[0][1].Dividends)
+	assert.InDelta(t, 10, inner[1][1].ROC, 0.001)
+
+	outer := Align([]NetWorthList{a, b}, JoinOuter)
+	assert.Len(t, outer[0], 5)
+	assert.Len(t, outer[1], 4)
+	assert.Equal(t, "2021-01-05", DateToString(outer[1][0].Date))
+	//缺失的净值沿用上一日
+	assert.Equal(t, "2021-01-07", DateToString(outer[0][3].Date))
+	assert.Equal(t, float32(1.21), outer[0][3].NAV)
+	assert.Equal(t, float32(0), outer[0][3].ROC)
+	assert.Equal(t, float32(2), outer[1][1].NAV)
+	assert.Equal(t, float32(10), outer[1][2].ROC)
+}
+
+func TestPackEngineLateInception(t *testing.T) {
+	s := Strategy{
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		SellPoint:   30,
+		CycleType:   CycleMonth,
+		CycleValue:  10,
+		VolaDays:    60,
+		FixedMethod: FixedInvest,
+		StartDate:   ParseDate("2020-01-01"),
+		EndDate:     ParseDate("2020-12-31"),
+	}
+	old, young := s, s
+	old.Code, young.Code = "old", "young"
+	nws := Synthetic{Start: ParseDate("2019-01-01"), Days: 520, Seed: 1}.Generate(GBM{Drift: 8, Vol: 10})
+	late := Synthetic{Start: ParseDate("2020-07-01"), Days: 130, Seed: 2}.Generate(GBM{Drift: 8, Vol: 10})
+	items := PackItemList{
+		{Engine: NewEngine(old, nws), TOF: Radical, Precent: 50},
+		{Engine: NewEngine(young, late), TOF: Radical, Precent: 50},
+	}
+	e := NewPackEngine(items, ParseDate("2020-01-01"), 2000)
+	e.SetEndDate(ParseDate("2020-12-31"))
+	e.SetLogger(log.New(ioutil.Discard, "", 0))
+	//成立前全部金额分配给已成立的项目
+	assert.Equal(t, float32(2000), items[0].strategy.BasicAmount)
+	assert.Equal(t, float32(0), items[1].strategy.BasicAmount)
+	result := e.Run()
+	assert.Equal(t, float32(1000), items[0].strategy.BasicAmount)
+	assert.Equal(t, float32(1000), items[1].strategy.BasicAmount)
+	first := result.Items[1].TransList[0]
+	assert.True(t, DiffDays(first.Date, ParseDate("2020-07-01")) >= 0)
+	assert.True(t, first.Amount > 0)
+	assert.Equal(t, float32(2000), result.Items[0].TransList[0].Amount)
+}
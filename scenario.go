@@ -0,0 +1,236 @@
+package backtesting
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	//Scenario 历史压力情景，指数走势按分段近似，段内波动由固定种子生成
+	Scenario struct {
+		Name  string
+		Start time.Time //原始情景的开始日期
+		Path  Script    //沪深300的分段走势
+		Seed  int64
+	}
+	//Exposure 基金对情景的暴露，Vol大于0时按波动率缩放，否则按Beta缩放
+	Exposure struct {
+		Beta float64 //相对指数的贝塔，默认1
+		Vol  float64 //基金的年化波动率(%)
+	}
+	//StressFund 参与压力测试的基金
+	StressFund struct {
+		Strategy Strategy
+		Nws      NetWorthList //历史净值，为空时按情景生成，用于情景发生时尚未成立的基金
+		Exposure Exposure
+		TOF      TOF //组合中的资金类型
+		Precent  int //组合中的分配比例
+	}
+	//StressTest 压力测试，将情景注入各基金的净值后分别回测单只基金和组合
+	StressTest struct {
+		Funds  []StressFund
+		At     time.Time   //情景注入的日期
+		After  int         //情景结束后继续回测的交易日数
+		Amount float32     //组合每月投入，为0时不回测组合
+		Logger *log.Logger //组合的交易日志
+	}
+	//ScenarioResult 单个情景的回测结果
+	ScenarioResult struct {
+		Scenario string
+		Drawdown float64     //情景内指数的最大回撤(%)
+		End      time.Time   //回测截止日期
+		Codes    []string    //各基金的代码
+		Results  []Result    //各基金单独回测的结果
+		Pack     *PackResult //组合回测的结果
+	}
+	//ScenarioResults 压力测试结果
+	ScenarioResults []ScenarioResult
+)
+
+var (
+	//Crisis2008 2008年金融危机，沪深300自2007年10月高点下跌七成后反弹
+	Crisis2008 = Scenario{
+		Name:  "2008金融危机",
+		Start: ParseDate("2007-10-16"),
+		Path:  Script{{Days: 120, Change: -45.5, Vol: 40}, {Days: 15, Change: 18, Vol: 35}, {Days: 130, Change: -58, Vol: 45}, {Days: 185, Change: 137, Vol: 35}},
+		Seed:  2008,
+	}
+	//Crash2015 2015年股灾，两轮急跌及2016年初熔断
+	Crash2015 = Scenario{
+		Name:  "2015股灾",
+		Start: ParseDate("2015-06-12"),
+		Path:  Script{{Days: 18, Change: -35, Vol: 60}, {Days: 27, Change: 15, Vol: 45}, {Days: 7, Change: -22, Vol: 50}, {Days: 80, Change: 16, Vol: 30}, {Days: 25, Change: -24, Vol: 40}},
+		Seed:  2015,
+	}
+	//Bear2018 2018年熊市，全年阴跌后2019年初反弹
+	Bear2018 = Scenario{
+		Name:  "2018熊市",
+		Start: ParseDate("2018-01-24"),
+		Path:  Script{{Days: 110, Change: -25, Vol: 22}, {Days: 20, Change: 5, Vol: 18}, {Days: 100, Change: -11, Vol: 20}, {Days: 62, Change: 40, Vol: 22}},
+		Seed:  2018,
+	}
+	//Scenarios 内置的情景
+	Scenarios = []Scenario{Crisis2008, Crash2015, Bear2018}
+)
+
+//Days 情景的交易日数
+func (sc Scenario) Days() int {
+	var days int
+	for _, seg := range sc.Path {
+		days += seg.Days
+	}
+	return days
+}
+
+//Index 情景的指数净值，首日为1，日期为原始日期
+func (sc Scenario) Index() NetWorthList {
+	return Synthetic{Start: sc.Start, Days: sc.Days() + 1, Seed: sc.Seed}.Generate(sc.Path)
+}
+
+//returns 按暴露缩放后的日涨跌幅(%)
+func (sc Scenario) returns(x Exposure) []float32 {
+	index := sc.Index()
+	k := x.Beta
+	if x.Vol > 0 {
+		if vol := index.Volatility(); vol > 0 {
+			k = x.Vol / vol
+		}
+	} else if k == 0 {
+		k = 1
+	}
+	items := make([]float32, 0, len(index)-1)
+	for _, nw := range index[1:] {
+		roc := float32(k) * nw.ROC
+		if roc < -99 {
+			roc = -99
+		}
+		items = append(items, roc)
+	}
+	return items
+}
+
+//Apply 从at起用情景的涨跌幅替换净值，之后的净值按比例平移。净值不足时按工作日补齐
+func (sc Scenario) Apply(nws NetWorthList, at time.Time, x Exposure) NetWorthList {
+	returns := sc.returns(x)
+	items := append(NetWorthList(nil), nws...)
+	i := 0
+	for i < len(items) && DiffDays(items[i].Date, at) < 0 {
+		i++
+	}
+	var factor float32 = 1
+	var last NetWorth
+	if i > 0 {
+		last = items[i-1]
+	} else {
+		last = NetWorth{Date: at.AddDate(0, 0, -1), NAV: 1, CNAV: 1}
+	}
+	for k, roc := range returns {
+		j := i + k
+		if j >= len(items) {
+			date := last.Date.AddDate(0, 0, 1)
+			for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+				date = date.AddDate(0, 0, 1)
+			}
+			items = append(items, NetWorth{Date: date, NAV: last.NAV, CNAV: last.CNAV})
+			factor = 1
+		}
+		nw := &items[j]
+		nw.NAV, nw.CNAV, nw.Dividends = nw.NAV*factor, nw.CNAV*factor, nw.Dividends*factor
+		factor *= (1 + roc/100) / (1 + nw.ROC/100)
+		nw.NAV *= (1 + roc/100) / (1 + nw.ROC/100)
+		nw.CNAV *= (1 + roc/100) / (1 + nw.ROC/100)
+		nw.ROC = roc
+		last = *nw
+	}
+	for j := i + len(returns); j < len(items); j++ {
+		nw := &items[j]
+		nw.NAV, nw.CNAV, nw.Dividends = nw.NAV*factor, nw.CNAV*factor, nw.Dividends*factor
+	}
+	return items
+}
+
+//Run 依次运行各情景
+func (t StressTest) Run(scenarios []Scenario) ScenarioResults {
+	items := make(ScenarioResults, 0, len(scenarios))
+	for _, sc := range scenarios {
+		items = append(items, t.run(sc))
+	}
+	return items
+}
+
+//run 运行单个情景
+func (t StressTest) run(sc Scenario) ScenarioResult {
+	r := ScenarioResult{Scenario: sc.Name, Drawdown: sc.Index().MaxDrawdown()}
+	nws := make([]NetWorthList, len(t.Funds))
+	for k, f := range t.Funds {
+		nws[k] = sc.Apply(f.Nws, t.At, f.Exposure)
+		if n := stressEnd(nws[k], t.At, sc.Days()+t.After); r.End.IsZero() || n.Before(r.End) {
+			r.End = n
+		}
+	}
+	for k, f := range t.Funds {
+		r.Codes = append(r.Codes, f.Strategy.Code)
+		r.Results = append(r.Results, NewEngine(t.strategy(f.Strategy, r.End), nws[k]).Run())
+	}
+	if t.Amount <= 0 || len(t.Funds) == 0 {
+		return r
+	}
+	items := make(PackItemList, 0, len(t.Funds))
+	start := t.At
+	for k, f := range t.Funds {
+		s := t.strategy(f.Strategy, r.End)
+		if s.StartDate.Before(start) {
+			start = s.StartDate
+		}
+		items = append(items, PackItem{Engine: NewEngine(s, nws[k]), TOF: f.TOF, Precent: f.Precent})
+	}
+	e := NewPackEngine(items, start, t.Amount)
+	e.SetEndDate(r.End)
+	if t.Logger != nil {
+		e.SetLogger(t.Logger)
+	}
+	pack := e.Run()
+	r.Pack = &pack
+	return r
+}
+
+//strategy 将策略的区间限制在情景内，开始日期晚于情景时从情景开始
+func (t StressTest) strategy(s Strategy, end time.Time) Strategy {
+	if s.StartDate.IsZero() || s.StartDate.After(t.At) {
+		s.StartDate = t.At
+	}
+	s.EndDate = end
+	return s
+}
+
+//stressEnd 情景开始后第days个交易日，净值不足时为最后一天
+func stressEnd(nws NetWorthList, at time.Time, days int) time.Time {
+	i := 0
+	for i < len(nws) && DiffDays(nws[i].Date, at) < 0 {
+		i++
+	}
+	if i+days >= len(nws) {
+		return nws[len(nws)-1].Date
+	}
+	return nws[i+days].Date
+}
+
+//Table 输出各情景的回测结果，组合在每个情景的最后一行
+func (items ScenarioResults) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "情景\t指数回撤\t代码\t本金\t价值\t利润\t收益率\t年化")
+	for _, r := range items {
+		for k, res := range r.Results {
+			fmt.Fprintf(tw, "%s\t%.2f\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n",
+				r.Scenario, r.Drawdown, r.Codes[k], res.Invest, res.Value, res.Profit, res.Rop, res.XIRR())
+		}
+		if r.Pack != nil {
+			fmt.Fprintf(tw, "%s\t%.2f\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+				r.Scenario, r.Drawdown, "组合", r.Pack.Invest, r.Pack.Value, r.Pack.Profit, r.Pack.Rop)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,89 @@
+package backtesting
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioIndex(t *testing.T) {
+	for _, sc := range Scenarios {
+		index := sc.Index()
+		assert.Len(t, index, sc.Days()+1)
+		assert.Equal(t, sc.Start, index[0].Date)
+	}
+	//沪深300自5877点跌至1606点
+	assert.InDelta(t, 72, Crisis2008.Index().MaxDrawdown(), 5)
+	assert.InDelta(t, 0.65, Crash2015.Index()[18].NAV, 0.001)
+}
+
+func TestScenarioApply(t *testing.T) {
+	nws := growNws("2020-01-01", 400, 0)
+	at := ParseDate("2020-03-02")
+	items := Bear2018.Apply(nws, at, Exposure{Beta: 0.5})
+	assert.Len(t, items, len(nws))
+	assert.Equal(t, float32(1), nws[100].NAV)
+	k, _ := items.Today(at)
+	index := Bear2018.Index()
+	assert.InDelta(t, index[1].ROC/2, items[k].ROC, 0.0001)
+	//情景结束后净值按比例平移，涨跌幅不变
+	end := k + Bear2018.Days() - 1
+	assert.InDelta(t, items[end].NAV, items[end+1].NAV, 0.0001)
+	assert.Equal(t, items[0], nws[0])
+
+	//按波动率缩放
+	items = Crash2015.Apply(nws, at, Exposure{Vol: 10})
+	k, _ = items.Today(at)
+	assert.InDelta(t, 10, items[k-1:k+Crash2015.Days()].Volatility(), 0.5)
+
+	//没有净值的基金直接生成情景
+	items = Crisis2008.Apply(nil, at, Exposure{})
+	assert.Len(t, items, Crisis2008.Days())
+	assert.Equal(t, at, items[0].Date)
+	assert.InDelta(t, index[len(index)-1].NAV/index[0].NAV, Bear2018.Apply(nil, at, Exposure{})[Bear2018.Days()-1].NAV, 0.001)
+}
+
+func TestStressTest(t *testing.T) {
+	s := Strategy{
+		Code:        "equity",
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   5000,
+		SellPoint:   20,
+		CycleType:   CycleMonth,
+		CycleValue:  1,
+		VolaDays:    60,
+		FixedMethod: FloatInvest,
+	}
+	bond := s
+	bond.Code = "bond"
+	st := StressTest{
+		Funds: []StressFund{
+			{Strategy: s, Nws: growNws("2019-01-01", 800, 0.0003), Exposure: Exposure{Beta: 1}, TOF: Radical, Precent: 70},
+			{Strategy: bond, Nws: growNws("2019-01-01", 800, 0.0001), Exposure: Exposure{Beta: 0.1}, TOF: Conservative, Precent: 30},
+		},
+		At:     ParseDate("2020-01-01"),
+		After:  20,
+		Amount: 5000,
+		Logger: log.New(ioutil.Discard, "", 0),
+	}
+	results := st.Run(Scenarios)
+	assert.Len(t, results, 3)
+	for _, r := range results {
+		assert.Len(t, r.Results, 2)
+		assert.NotNil(t, r.Pack)
+		assert.True(t, r.Results[0].Invest > 0)
+		assert.Equal(t, r.End, r.Results[0].Date)
+	}
+	//2015情景结束于下跌段，股票暴露更高亏损更多
+	assert.True(t, results[1].Results[0].Rop < results[1].Results[1].Rop)
+	assert.True(t, results[1].Pack.Profit < 0)
+
+	var buf bytes.Buffer
+	assert.Nil(t, results.Table(&buf))
+	assert.Contains(t, buf.String(), "2015股灾")
+	assert.Contains(t, buf.String(), "组合")
+}
@@ -0,0 +1,174 @@
+package backtesting
+
+import (
+	"math"
+	"time"
+)
+
+type (
+	//Schedule 投入计划，组合引擎与单基金引擎共用
+	Schedule struct {
+		Amount     float32                //每期基准投入金额
+		GrowthRate float32                //每年投入增长率(%)
+		StartDate  time.Time              //计划开始日期，满一年增长一次
+		Bonus      map[time.Month]float32 //每年固定月份的一次性投入，如年终奖
+		Pauses     []Period               //暂停投入的区间，暂停期间不投入定期金额及年度一次性投入
+		Flows      []Flow                 //自定义日期的投入
+		Deposits   []Flow                 //自定义日期存入余额的资金，计入投入但不直接买入，由之后的买入优先使用
+	}
+	//Period 日期区间，包含起止日期
+	Period struct {
+		Start time.Time
+		End   time.Time
+	}
+	//Flow 指定日期的投入
+	Flow struct {
+		Date   time.Time
+		Amount float32
+	}
+)
+
+//Contains 日期是否在区间内
+func (p Period) Contains(date time.Time) bool {
+	return DiffDays(date, p.Start) >= 0 && DiffDays(date, p.End) <= 0
+}
+
+//Paused 是否暂停投入
+func (s Schedule) Paused(date time.Time) bool {
+	for _, p := range s.Pauses {
+		if p.Contains(date) {
+			return true
+		}
+	}
+	return false
+}
+
+//AmountAt 指定日期的定期投入金额，暂停时为0
+func (s Schedule) AmountAt(date time.Time) float32 {
+	if s.Paused(date) {
+		return 0
+	}
+	return s.Amount * s.growth(date)
+}
+
+//growth 按整年计算的投入增长倍数
+func (s Schedule) growth(date time.Time) float32 {
+	if s.GrowthRate == 0 || s.StartDate.IsZero() {
+		return 1
+	}
+	years := date.Year() - s.StartDate.Year()
+	if date.Month() < s.StartDate.Month() || (date.Month() == s.StartDate.Month() && date.Day() < s.StartDate.Day()) {
+		years--
+	}
+	if years <= 0 {
+		return 1
+	}
+	return float32(math.Pow(1+float64(s.GrowthRate)/100, float64(years)))
+}
+
+//Contribute 区间(from, to]内的一次性投入，包括年度固定月份投入和自定义日期投入
+func (s Schedule) Contribute(from, to time.Time) float32 {
+	var amount float32
+	for _, f := range s.Flows {
+		if DiffDays(f.Date, from) > 0 && DiffDays(f.Date, to) <= 0 {
+			amount += f.Amount
+		}
+	}
+	if len(s.Bonus) == 0 {
+		return amount
+	}
+	//年度一次性投入按当月1日计算
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	for ; DiffDays(month, to) <= 0; month = month.AddDate(0, 1, 0) {
+		if DiffDays(month, from) <= 0 || s.Paused(month) {
+			continue
+		}
+		if !s.StartDate.IsZero() && DiffDays(month, s.StartDate) < 0 {
+			continue
+		}
+		amount += s.Bonus[month.Month()] * s.growth(month)
+	}
+	return amount
+}
+
+//Deposited 区间(from, to]内存入余额的资金
+func (s Schedule) Deposited(from, to time.Time) float32 {
+	var amount float32
+	for _, f := range s.Deposits {
+		if DiffDays(f.Date, from) > 0 && DiffDays(f.Date, to) <= 0 {
+			amount += f.Amount
+		}
+	}
+	return amount
+}
+
+//SetSchedule 设置投入计划。定期投入金额按计划增长或暂停，一次性投入在当日买入
+func (ctx *Engine) SetSchedule(s Schedule) {
+	if s.StartDate.IsZero() {
+		s.StartDate = ctx.strategy.StartDate
+	}
+	ctx.schedule = &s
+}
+
+//basicAmount 当日的投入基准金额
+func (ctx *Engine) basicAmount(date time.Time) float32 {
+	if ctx.schedule == nil {
+		return ctx.strategy.BasicAmount
+	}
+	return ctx.schedule.AmountAt(date)
+}
+
+//paused 投入计划是否暂停
+func (ctx *Engine) paused(date time.Time) bool {
+	return ctx.schedule != nil && ctx.schedule.Paused(date)
+}
+
+//growth 投入计划增长倍数，用于同步调整最大最小投入
+func (ctx *Engine) growth(date time.Time) float32 {
+	if ctx.schedule == nil {
+		return 1
+	}
+	return ctx.schedule.growth(date)
+}
+
+//contribute 按投入计划买入一次性投入金额，记为一次性投入，不影响定投日的判断
+func (ctx *Engine) contribute(nw NetWorth) *Transaction {
+	if ctx.schedule == nil {
+		return nil
+	}
+	from := ctx.flowed
+	if from.IsZero() {
+		from = ctx.strategy.StartDate.AddDate(0, 0, -1)
+	}
+	ctx.flowed = nw.Date
+	if deposit := ctx.schedule.Deposited(from, nw.Date); deposit > 0 {
+		ctx.deposit += deposit
+		ctx.invest += deposit
+	}
+	amount := ctx.schedule.Contribute(from, nw.Date)
+	if amount = ctx.purchasable(amount, nw, TransContribute); amount <= 0 {
+		return nil
+	}
+	ctx.invest += amount
+	return ctx.buy(nw, amount, ctx.buyRate(), TransContribute)
+}
+
+//SetSchedule 设置组合的投入计划
+func (e *PackEngine) SetSchedule(s Schedule) {
+	if s.StartDate.IsZero() {
+		s.StartDate = e.startDate
+	}
+	e.schedule = s
+	e.amount = s.Amount
+	e.allot()
+}
+
+//contribute 组合当日的一次性投入
+func (e *PackEngine) contribute(now time.Time) {
+	from := e.flowed
+	if from.IsZero() {
+		from = e.startDate.AddDate(0, 0, -1)
+	}
+	e.flowed = now
+	e.AddBanlance(e.schedule.Contribute(from, now) + e.schedule.Deposited(from, now))
+}
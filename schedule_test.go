@@ -0,0 +1,104 @@
+package backtesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	s := Schedule{
+		Amount:     1000,
+		GrowthRate: 10,
+		StartDate:  ParseDate("2020-03-01"),
+		Bonus:      map[time.Month]float32{time.January: 5000},
+		Pauses:     []Period{{Start: ParseDate("2021-06-01"), End: ParseDate("2021-08-31")}},
+		Flows:      []Flow{{Date: ParseDate("2020-05-20"), Amount: 20000}},
+	}
+	assert.Equal(t, float32(1000), s.AmountAt(ParseDate("2021-02-28")))
+	assert.InDelta(t, 1100, s.AmountAt(ParseDate("2021-03-01")), 0.01)
+	assert.InDelta(t, 1210, s.AmountAt(ParseDate("2022-04-01")), 0.01)
+	assert.Equal(t, float32(0), s.AmountAt(ParseDate("2021-07-15")))
+	assert.True(t, s.Paused(ParseDate("2021-08-31")))
+	assert.False(t, s.Paused(ParseDate("2021-09-01")))
+
+	assert.Equal(t, float32(20000), s.Contribute(ParseDate("2020-05-19"), ParseDate("2020-05-22")))
+	assert.Equal(t, float32(0), s.Contribute(ParseDate("2020-05-20"), ParseDate("2020-05-22")))
+	//年度一次性投入同样按计划增长
+	assert.Equal(t, float32(5000), s.Contribute(ParseDate("2020-12-31"), ParseDate("2021-01-04")))
+	assert.InDelta(t, 5500, s.Contribute(ParseDate("2021-12-31"), ParseDate("2022-01-04")), 0.01)
+	//开始日期之前的年度投入不计算
+	assert.Equal(t, float32(0), s.Contribute(ParseDate("2019-12-31"), ParseDate("2020-01-04")))
+}
+
+func TestEngineSchedule(t *testing.T) {
+	start := ParseDate("2020-01-01")
+	var nws NetWorthList
+	for i := 0; i < 800; i++ {
+		nws = append(nws, NetWorth{Date: start.AddDate(0, 0, i), NAV: 1})
+	}
+	e := NewEngine(Strategy{
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		StartDate:   start,
+		EndDate:     start.AddDate(2, 0, 0),
+		CycleType:   CycleMonth,
+		CycleValue:  10,
+		FixedMethod: FixedInvest,
+	}, nws)
+	e.SetSchedule(Schedule{
+		Amount:     1000,
+		GrowthRate: 10,
+		Pauses:     []Period{{Start: ParseDate("2020-06-01"), End: ParseDate("2020-07-31")}},
+		Flows:      []Flow{{Date: ParseDate("2020-03-01"), Amount: 5000}},
+	})
+	result := e.Run()
+	var fixed []Transaction
+	for _, trans := range result.TransList {
+		if trans.TransType == TransFixed {
+			fixed = append(fixed, trans)
+		}
+	}
+	//24个月定投去掉暂停的2个月，一次性投入单独记录
+	assert.Len(t, fixed, 22)
+	assert.Equal(t, float32(5000), result.TransList.LastByType(TransContribute).Amount)
+	assert.Equal(t, float32(1100), fixed[len(fixed)-1].Amount)
+	assert.Equal(t, float32(1000*10+5000+1100*12), result.Invest)
+}
+
+func TestEngineContribution(t *testing.T) {
+	start := ParseDate("2020-01-01")
+	var nws NetWorthList
+	for i := 0; i < 200; i++ {
+		nws = append(nws, NetWorth{Date: start.AddDate(0, 0, i), NAV: 1})
+	}
+	run := func(cycle CycleType, value int, flows ...Flow) []string {
+		e := NewEngine(Strategy{
+			BasicAmount: 1000,
+			MinAmount:   100,
+			MaxAmount:   10000,
+			StartDate:   start,
+			EndDate:     ParseDate("2020-06-30"),
+			CycleType:   cycle,
+			CycleValue:  value,
+			FixedMethod: FixedInvest,
+		}, nws)
+		e.SetSchedule(Schedule{Amount: 1000, Flows: flows})
+		var dates []string
+		for _, trans := range e.Run().TransList {
+			if trans.TransType == TransFixed {
+				dates = append(dates, DateToString(trans.Date))
+			}
+		}
+		return dates
+	}
+	//一次性投入在定投日及定投日之前都不影响当月定投
+	monthly := run(CycleMonth, 10)
+	assert.Len(t, monthly, 6)
+	assert.Equal(t, monthly, run(CycleMonth, 10, Flow{Date: ParseDate(monthly[2]), Amount: 5000}, Flow{Date: ParseDate("2020-04-01"), Amount: 5000}))
+	//两周定投的间隔不受一次性投入影响
+	biweekly := run(CycleTowWeek, 3)
+	assert.Equal(t, biweekly, run(CycleTowWeek, 3, Flow{Date: ParseDate(biweekly[2]), Amount: 5000}, Flow{Date: ParseDate("2020-02-03"), Amount: 5000}))
+}
@@ -0,0 +1,306 @@
+package backtesting
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	//Profile 基金概况
+	Profile struct {
+		Basic
+		Type         string    //基金类型
+		AUM          float64   //资产规模（亿元）
+		Inception    time.Time //成立日期
+		Manager      string    //现任基金经理
+		ManagerSince time.Time //现任基金经理任职日期
+	}
+	//Metric 筛选指标
+	Metric int
+	//Filter 指标过滤条件，Min和Max为0时不限制
+	Filter struct {
+		Metric Metric
+		Years  int //统计最近的年数，0为全部历史
+		Min    float64
+		Max    float64
+	}
+	//Weight 综合评分的指标权重。回撤和波动率越低得分越高
+	Weight struct {
+		Metric Metric
+		Years  int
+		Weight float64
+	}
+	//Screener 基金筛选
+	Screener struct {
+		Types     []string                           //基金类型，包含任一关键字即可，如"股票型"、"混合型"
+		MinAUM    float64                            //最小资产规模（亿元）
+		MaxAUM    float64                            //最大资产规模（亿元），为0时不限制
+		Before    time.Time                          //成立日期早于该日期
+		MinTenure float64                            //现任基金经理最短任职年数
+		Filters   []Filter                           //历史指标过滤
+		Weights   []Weight                           //综合评分权重
+		RiskFree  float64                            //无风险年化收益率(%)
+		End       time.Time                          //指标统计截止日期，默认为当前日期
+		Workers   int                                //并发数，默认4
+		Profiler  func(code string) (Profile, error) //基金概况获取，默认从天天基金获取
+		Loader    Loader                             //净值加载，默认从天天基金获取
+	}
+	//Candidate 筛选出的候选基金
+	Candidate struct {
+		Profile
+		Metrics map[string]float64 //指标值，键为指标名称加年数，如 CAGR3
+		Score   float64            //综合评分
+		nws     NetWorthList
+	}
+	//Candidates 候选基金列表，按综合评分从高到低排列
+	Candidates []Candidate
+)
+
+const (
+	//MetricCAGR 年化收益率
+	MetricCAGR Metric = 1
+	//MetricDrawdown 最大回撤
+	MetricDrawdown Metric = 2
+	//MetricVolatility 年化波动率
+	MetricVolatility Metric = 3
+	//MetricSharpe 夏普比率
+	MetricSharpe Metric = 4
+)
+
+func (m Metric) String() string {
+	switch m {
+	case MetricCAGR:
+		return "CAGR"
+	case MetricDrawdown:
+		return "MaxDrawdown"
+	case MetricVolatility:
+		return "Volatility"
+	case MetricSharpe:
+		return "Sharpe"
+	}
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+//key 指标名称加统计年数
+func (m Metric) key(years int) string {
+	return fmt.Sprintf("%s%d", m, years)
+}
+
+//Metric 获取指标值，没有计算时返回NaN
+func (c Candidate) Metric(m Metric, years int) float64 {
+	if v, ok := c.Metrics[m.key(years)]; ok {
+		return v
+	}
+	return math.NaN()
+}
+
+//Screen 按概况和历史指标筛选基金，并按综合评分排序
+func (s Screener) Screen(funds []Basic) (Candidates, error) {
+	if s.End.IsZero() {
+		s.End = time.Now()
+	}
+	if s.Profiler == nil {
+		s.Profiler = TianTian.GetProfile
+	}
+	if s.Loader == nil {
+		s.Loader = TianTianLoader(s.inception(), s.End)
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	var mu sync.Mutex
+	var items Candidates
+	var errs []string
+	parallel(len(funds), workers, func(i int) {
+		c, ok, err := s.screen(funds[i])
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", funds[i].Code, err))
+		} else if ok {
+			items = append(items, c)
+		}
+	})
+	s.score(items)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return items[i].Code < items[j].Code
+	})
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return items, fmt.Errorf("部分基金筛选失败: %s", strings.Join(errs, "; "))
+	}
+	return items, nil
+}
+
+//inception 需要加载的最早日期
+func (s Screener) inception() time.Time {
+	years := 0
+	for _, f := range s.Filters {
+		if f.Years == 0 {
+			return time.Time{}
+		}
+		if f.Years > years {
+			years = f.Years
+		}
+	}
+	for _, w := range s.Weights {
+		if w.Years == 0 {
+			return time.Time{}
+		}
+		if w.Years > years {
+			years = w.Years
+		}
+	}
+	return s.End.AddDate(-years, 0, 0)
+}
+
+//screen 筛选单只基金
+func (s Screener) screen(fund Basic) (Candidate, bool, error) {
+	profile, err := s.Profiler(fund.Code)
+	if err != nil {
+		return Candidate{}, false, err
+	}
+	if profile.Code == "" {
+		profile.Code = fund.Code
+	}
+	if profile.Name == "" {
+		profile.Name = fund.Name
+	}
+	if !s.match(profile) {
+		return Candidate{}, false, nil
+	}
+	nws, err := s.Loader(fund.Code)
+	if err != nil {
+		return Candidate{}, false, err
+	}
+	c := Candidate{Profile: profile, Metrics: map[string]float64{}, nws: nws}
+	for _, f := range s.Filters {
+		v := s.metric(nws, f.Metric, f.Years)
+		c.Metrics[f.Metric.key(f.Years)] = v
+		if math.IsNaN(v) || (f.Min != 0 && v < f.Min) || (f.Max != 0 && v > f.Max) {
+			return c, false, nil
+		}
+	}
+	for _, w := range s.Weights {
+		c.Metrics[w.Metric.key(w.Years)] = s.metric(nws, w.Metric, w.Years)
+	}
+	return c, true, nil
+}
+
+//match 概况是否符合条件
+func (s Screener) match(p Profile) bool {
+	if len(s.Types) > 0 {
+		ok := false
+		for _, t := range s.Types {
+			if strings.Contains(p.Type, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if p.AUM < s.MinAUM || (s.MaxAUM > 0 && p.AUM > s.MaxAUM) {
+		return false
+	}
+	if !s.Before.IsZero() && (p.Inception.IsZero() || !p.Inception.Before(s.Before)) {
+		return false
+	}
+	if s.MinTenure > 0 {
+		if p.ManagerSince.IsZero() || float64(DiffDays(s.End, p.ManagerSince))/365 < s.MinTenure {
+			return false
+		}
+	}
+	return true
+}
+
+//metric 计算最近years年的指标，历史不足years年时返回NaN
+func (s Screener) metric(nws NetWorthList, m Metric, years int) float64 {
+	window := nws.Window(time.Time{}, s.End)
+	if years > 0 {
+		start := s.End.AddDate(-years, 0, 0)
+		if len(window) == 0 || DiffDays(window[0].Date, start.AddDate(0, 0, 7)) > 0 {
+			return math.NaN()
+		}
+		window = window.Window(start, s.End)
+	}
+	if len(window) < 2 {
+		return math.NaN()
+	}
+	switch m {
+	case MetricCAGR:
+		return window.CAGR()
+	case MetricDrawdown:
+		return window.MaxDrawdown()
+	case MetricVolatility:
+		return window.Volatility()
+	case MetricSharpe:
+		return window.Sharpe(s.RiskFree)
+	}
+	return math.NaN()
+}
+
+//score 按标准分加权计算综合评分
+func (s Screener) score(items Candidates) {
+	for _, w := range s.Weights {
+		key := w.Metric.key(w.Years)
+		var values []float64
+		for _, c := range items {
+			if v := c.Metrics[key]; !math.IsNaN(v) {
+				values = append(values, v)
+			}
+		}
+		mu := mean(values)
+		var sd float64
+		for _, v := range values {
+			sd += (v - mu) * (v - mu)
+		}
+		if len(values) > 1 {
+			sd = math.Sqrt(sd / float64(len(values)-1))
+		}
+		sign := 1.0
+		if w.Metric == MetricDrawdown || w.Metric == MetricVolatility {
+			sign = -1
+		}
+		for k := range items {
+			v := items[k].Metrics[key]
+			if math.IsNaN(v) || sd == 0 {
+				continue
+			}
+			items[k].Score += sign * w.Weight * (v - mu) / sd
+		}
+	}
+}
+
+//Top 前n只候选基金
+func (items Candidates) Top(n int) Candidates {
+	if n < len(items) {
+		return items[:n]
+	}
+	return items
+}
+
+//PackItems 按策略模板创建组合项目，各项目平均分配比例，可直接用于NewPackEngine
+func (items Candidates) PackItems(strategy Strategy, tof TOF) PackItemList {
+	list := make(PackItemList, 0, len(items))
+	precents := apportion(equalWeights(len(items)), 100)
+	for k, c := range items {
+		s := strategy
+		s.Code = c.Code
+		list = append(list, PackItem{
+			Engine:  NewEngine(s, c.nws),
+			TOF:     tof,
+			Precent: precents[k],
+		})
+	}
+	return list
+}
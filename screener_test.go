@@ -0,0 +1,67 @@
+package backtesting
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreener(t *testing.T) {
+	profiles := map[string]Profile{
+		"000001": {Type: "股票型", AUM: 50, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2016-01-01")},
+		"000002": {Type: "混合型-偏股", AUM: 20, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2016-01-01")},
+		"000003": {Type: "债券型", AUM: 80, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2016-01-01")},
+		"000004": {Type: "股票型", AUM: 1, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2016-01-01")},
+		"000005": {Type: "股票型", AUM: 30, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2020-06-01")},
+		"000006": {Type: "股票型", AUM: 30, Inception: ParseDate("2015-01-01"), ManagerSince: ParseDate("2016-01-01")},
+	}
+	trend := func(roc float32) NetWorthList {
+		nws := flatNws("2015-01-01", 2500, 1)
+		for i := range nws {
+			nws[i].ROC = roc
+			if i%10 == 0 {
+				nws[i].ROC = -roc * 3
+			}
+		}
+		return nws
+	}
+	s := Screener{
+		Types:     []string{"股票", "混合"},
+		MinAUM:    2,
+		MinTenure: 3,
+		End:       ParseDate("2021-01-01"),
+		Filters:   []Filter{{Metric: MetricCAGR, Years: 3, Min: 1}},
+		Weights:   []Weight{{Metric: MetricSharpe, Years: 3, Weight: 1}, {Metric: MetricDrawdown, Years: 5, Weight: 0.5}},
+		Profiler: func(code string) (Profile, error) {
+			if code == "000006" {
+				return Profile{}, errors.New("超时")
+			}
+			return profiles[code], nil
+		},
+		Loader: func(code string) (NetWorthList, error) {
+			if code == "000002" {
+				return trend(0.1), nil
+			}
+			return trend(0.2), nil
+		},
+	}
+	var funds []Basic
+	for _, code := range []string{"000001", "000002", "000003", "000004", "000005", "000006"} {
+		funds = append(funds, Basic{Code: code, Name: "基金" + code})
+	}
+	items, err := s.Screen(funds)
+	assert.Error(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "000001", items[0].Code)
+	assert.Equal(t, "基金000001", items[0].Name)
+	assert.True(t, items[0].Score > items[1].Score)
+	assert.False(t, math.IsNaN(items[0].Metric(MetricSharpe, 3)))
+	assert.True(t, math.IsNaN(items[0].Metric(MetricVolatility, 1)))
+
+	pack := items.Top(1).PackItems(Strategy{CycleType: CycleMonth, CycleValue: 15}, Radical)
+	assert.Len(t, pack, 1)
+	assert.Equal(t, 100, pack[0].Precent)
+	assert.Equal(t, "000001", pack[0].strategy.Code)
+}
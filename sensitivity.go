@@ -0,0 +1,185 @@
+package backtesting
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"text/tabwriter"
+)
+
+type (
+	//FeeSweep 费率敏感性分析，按申购、赎回、转换费率的组合重新回测。费率为空时沿用策略的费率。
+	//扫描时关闭策略的份额类别费率，统一使用TransRate和SellRate
+	FeeSweep struct {
+		Buy    []float32 //申购费率(%)
+		Sell   []float32 //赎回费率(%)
+		Switch []float32 //转换补差费率(%)，仅用于组合
+		Max    float32   //求解盈亏平衡费率的上限(%)，默认5
+	}
+	//FeePoint 一组费率的回测结果
+	FeePoint struct {
+		Buy       float32
+		Sell      float32
+		Switch    float32
+		XIRR      float64 //年化收益率(%)
+		Value     float32
+		Profit    float32
+		XIRRDrop  float64 //相对零费率下降的年化收益率(%)
+		ValueDrop float32 //相对零费率减少的价值
+	}
+	//FeeReport 费率敏感性分析结果
+	FeeReport struct {
+		Base      FeePoint   //零费率的结果
+		Points    []FeePoint //各组费率的结果
+		Plain     []FeePoint //普通定投在各组费率下的结果，与Points对应
+		BreakEven float32    //申购和赎回费率相同时，策略年化不再优于普通定投的费率(%)。零费率时已不优于为0，上限内始终优于为-1
+	}
+)
+
+//fees 设置策略的费率
+func (s Strategy) fees(buy, sell float32) Strategy {
+	s.TransRate, s.SellRate = buy, sell
+	s.Fee = FeeSchedule{}
+	return s
+}
+
+//plain 关闭追加、卖出及不定额规则的普通定投
+func (s Strategy) plain() Strategy {
+	s.Disable |= RuleAppend | RuleSell | RuleFloat
+	return s
+}
+
+//grid 申购、赎回及转换费率的全部组合
+func (f FeeSweep) grid(s Strategy) []FeePoint {
+	buys, sells, switches := f.Buy, f.Sell, f.Switch
+	if len(buys) == 0 {
+		buys = []float32{s.TransRate}
+	}
+	if len(sells) == 0 {
+		sells = []float32{s.SellRate}
+	}
+	if len(switches) == 0 {
+		switches = []float32{0}
+	}
+	var points []FeePoint
+	for _, b := range buys {
+		for _, sl := range sells {
+			for _, sw := range switches {
+				points = append(points, FeePoint{Buy: b, Sell: sl, Switch: sw})
+			}
+		}
+	}
+	return points
+}
+
+//run 按费率回测单只基金
+func (p FeePoint) run(s Strategy, nws NetWorthList) FeePoint {
+	r := NewEngine(s.fees(p.Buy, p.Sell), nws).Run()
+	p.XIRR, p.Value, p.Profit = r.XIRR(), r.Value, r.Profit
+	return p
+}
+
+//drop 相对零费率的下降
+func (p FeePoint) drop(base FeePoint) FeePoint {
+	p.XIRRDrop = base.XIRR - p.XIRR
+	p.ValueDrop = base.Value - p.Value
+	return p
+}
+
+//Run 对单只基金扫描费率，并与相同费率下的普通定投对比
+func (f FeeSweep) Run(s Strategy, nws NetWorthList) FeeReport {
+	r := FeeReport{Base: FeePoint{}.run(s, nws)}
+	for _, p := range f.grid(s) {
+		r.Points = append(r.Points, p.run(s, nws).drop(r.Base))
+		r.Plain = append(r.Plain, p.run(s.plain(), nws))
+	}
+	//策略相对普通定投的年化优势
+	r.BreakEven = f.breakEven(func(rate float32) float64 {
+		p := FeePoint{Buy: rate, Sell: rate}
+		return p.run(s, nws).XIRR - p.run(s.plain(), nws).XIRR
+	})
+	return r
+}
+
+//breakEven 按策略相对普通定投的年化优势求解盈亏平衡费率
+func (f FeeSweep) breakEven(edge func(rate float32) float64) float32 {
+	max := f.Max
+	if max <= 0 {
+		max = 5
+	}
+	switch lo, hi := edge(0), edge(max); {
+	case math.IsNaN(lo) || math.IsNaN(hi):
+		return -1
+	case lo <= 0:
+		return 0
+	case hi > 0:
+		return -1
+	}
+	var a, b float32 = 0, max
+	for i := 0; i < 30 && b-a > 0.001; i++ {
+		mid := (a + b) / 2
+		if edge(mid) > 0 {
+			a = mid
+		} else {
+			b = mid
+		}
+	}
+	return b
+}
+
+//RunPack 对组合扫描费率，build每次需创建新的组合。普通定投为各项目关闭追加、卖出及不定额规则的组合
+func (f FeeSweep) RunPack(build func() *PackEngine) FeeReport {
+	run := func(p FeePoint, plain bool) FeePoint {
+		e := build()
+		e.SetLogger(log.New(ioutil.Discard, "", 0))
+		for k := range e.items {
+			item := &e.items[k]
+			if plain {
+				item.strategy = item.strategy.plain()
+			}
+			item.strategy = item.strategy.fees(p.Buy, p.Sell)
+		}
+		if e.switchConf != nil {
+			conf := *e.switchConf
+			conf.Rate = p.Switch
+			e.SetSwitch(conf)
+		} else if p.Switch > 0 {
+			e.SetSwitch(SwitchConfig{Rate: p.Switch})
+		}
+		r := e.Run()
+		p.XIRR, p.Value, p.Profit = r.XIRR(), r.Value, r.Profit
+		return p
+	}
+	r := FeeReport{Base: run(FeePoint{}, false)}
+	var s Strategy
+	if e := build(); len(e.items) > 0 {
+		s = e.items[0].strategy
+	}
+	for _, p := range f.grid(s) {
+		r.Points = append(r.Points, run(p, false).drop(r.Base))
+		r.Plain = append(r.Plain, run(p, true))
+	}
+	r.BreakEven = f.breakEven(func(rate float32) float64 {
+		p := FeePoint{Buy: rate, Sell: rate}
+		return run(p, false).XIRR - run(p, true).XIRR
+	})
+	return r
+}
+
+//Table 输出各组费率的结果
+func (r FeeReport) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "零费率年化\t%.2f\t零费率价值\t%.2f\t盈亏平衡费率\t%.3f\n", r.Base.XIRR, r.Base.Value, r.BreakEven)
+	fmt.Fprintln(tw, "申购费率\t赎回费率\t转换费率\t年化\t价值\t利润\t年化下降\t价值减少\t定投年化")
+	for k, p := range r.Points {
+		plain := math.NaN()
+		if k < len(r.Plain) {
+			plain = r.Plain[k].XIRR
+		}
+		fmt.Fprintf(tw, "%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n",
+			p.Buy, p.Sell, p.Switch, p.XIRR, p.Value, p.Profit, p.XIRRDrop, p.ValueDrop, plain)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,86 @@
+package backtesting
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sweepStrategy() (Strategy, NetWorthList) {
+	nws := Synthetic{Start: ParseDate("2016-01-04"), Days: 1500, Seed: 7}.Generate(MeanRevert{Mean: 1.3, Speed: 1, Vol: 20})
+	s := Strategy{
+		Code:        "sweep",
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   10000,
+		SellPoint:   20,
+		StartDate:   ParseDate("2017-01-01"),
+		EndDate:     ParseDate("2021-09-30"),
+		TransRate:   0.15,
+		SellRate:    0.5,
+		CycleType:   CycleMonth,
+		CycleValue:  10,
+		VolaDays:    120,
+		FixedMethod: FloatInvest,
+	}
+	return s, nws
+}
+
+func TestFeeSweep(t *testing.T) {
+	s, nws := sweepStrategy()
+	r := FeeSweep{Buy: []float32{0, 0.5, 1.5}, Sell: []float32{0, 1.5}}.Run(s, nws)
+	assert.Len(t, r.Points, 6)
+	assert.Len(t, r.Plain, 6)
+	assert.Equal(t, r.Base.XIRR, r.Points[0].XIRR)
+	assert.Equal(t, float64(0), r.Points[0].XIRRDrop)
+	//费率越高收益越低
+	for k := 1; k < len(r.Points); k++ {
+		assert.True(t, r.Points[k].XIRRDrop > 0)
+	}
+	assert.True(t, r.Points[5].XIRR < r.Points[3].XIRR)
+	assert.True(t, r.Points[5].XIRR < r.Points[4].XIRR)
+
+	//盈亏平衡费率两侧策略与普通定投的优劣相反
+	assert.InDelta(t, 2.2, r.BreakEven, 0.1)
+	{
+		below, above := FeePoint{Buy: r.BreakEven * 0.8, Sell: r.BreakEven * 0.8}, FeePoint{Buy: r.BreakEven * 1.2, Sell: r.BreakEven * 1.2}
+		assert.True(t, below.run(s, nws).XIRR > below.run(s.plain(), nws).XIRR)
+		assert.True(t, above.run(s, nws).XIRR <= above.run(s.plain(), nws).XIRR)
+	}
+	//费率为空时沿用策略的费率
+	r = FeeSweep{}.Run(s, nws)
+	assert.Len(t, r.Points, 1)
+	assert.Equal(t, s.TransRate, r.Points[0].Buy)
+	assert.Equal(t, s.SellRate, r.Points[0].Sell)
+
+	var buf bytes.Buffer
+	assert.Nil(t, r.Table(&buf))
+	assert.Contains(t, buf.String(), "盈亏平衡费率")
+}
+
+func TestFeeSweepPack(t *testing.T) {
+	s, nws := sweepStrategy()
+	bond := s
+	bond.Code = "bond"
+	bnws := Synthetic{Start: ParseDate("2016-01-04"), Days: 1500, Seed: 8}.Generate(GBM{Drift: 4, Vol: 3})
+	build := func() *PackEngine {
+		items := PackItemList{
+			{Engine: NewEngine(s, nws), TOF: Radical, Precent: 60},
+			{Engine: NewEngine(bond, bnws), TOF: Conservative, Precent: 40},
+		}
+		e := NewPackEngine(items, s.StartDate, 5000)
+		e.SetEndDate(s.EndDate)
+		return e
+	}
+	r := FeeSweep{Buy: []float32{0.15}, Sell: []float32{0.5}, Switch: []float32{0, 1}}.RunPack(build)
+	assert.Len(t, r.Points, 2)
+	assert.Len(t, r.Plain, 2)
+	assert.False(t, math.IsNaN(r.Points[0].XIRR))
+	assert.True(t, r.Points[0].XIRRDrop > 0)
+	assert.True(t, r.Points[0].ValueDrop > 0)
+	assert.True(t, r.Points[1].Profit <= r.Points[0].Profit)
+	//组合同样求解相对普通定投的盈亏平衡费率
+	assert.True(t, r.BreakEven > 0 && r.BreakEven < 5)
+}
@@ -0,0 +1,182 @@
+package backtesting
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	//DeviationKind 偏差类型
+	DeviationKind int
+	//Deviation 实盘交易与策略建议的偏差
+	Deviation struct {
+		Date     time.Time
+		Kind     DeviationKind
+		Expected float32 //策略建议的金额
+		Actual   float32 //实际交易的金额
+		Cost     float32 //偏差造成的损失，按持有到期计算，负数表示偏差带来了收益
+	}
+	//ShadowReport 影子策略对比报告
+	ShadowReport struct {
+		Real       Holding     //实盘表现
+		Shadow     Result      //策略表现
+		Deviations []Deviation //偏差明细
+		Cost       float32     //偏差造成的总损失
+	}
+	//Shadow 影子策略对比参数
+	Shadow struct {
+		Strategy  Strategy //对比的策略，日期区间默认与实盘相同
+		Window    int      //交易日期相差不超过该天数视为同一次交易，默认3天
+		Tolerance float32  //金额相差不超过该比例(%)视为一致，默认10%
+	}
+)
+
+const (
+	//DeviationMissedBuy 策略建议买入但没有买入
+	DeviationMissedBuy DeviationKind = 1
+	//DeviationExtraBuy 策略没有建议的买入
+	DeviationExtraBuy DeviationKind = 2
+	//DeviationMissedSell 策略建议卖出但没有卖出
+	DeviationMissedSell DeviationKind = 3
+	//DeviationEarlySell 策略没有建议的卖出
+	DeviationEarlySell DeviationKind = 4
+	//DeviationAmount 交易金额与建议不一致
+	DeviationAmount DeviationKind = 5
+)
+
+func (k DeviationKind) String() string {
+	switch k {
+	case DeviationMissedBuy:
+		return "漏买"
+	case DeviationExtraBuy:
+		return "计划外买入"
+	case DeviationMissedSell:
+		return "漏卖"
+	case DeviationEarlySell:
+		return "计划外卖出"
+	case DeviationAmount:
+		return "金额偏差"
+	}
+	return fmt.Sprintf("DeviationKind(%d)", int(k))
+}
+
+//Compare 在实盘的日期区间内按实盘的资金投入运行策略，逐笔对比实盘交易与策略建议
+func (s Shadow) Compare(trans TransactionList, nws NetWorthList) ShadowReport {
+	if s.Window <= 0 {
+		s.Window = 3
+	}
+	if s.Tolerance <= 0 {
+		s.Tolerance = 10
+	}
+	report := ShadowReport{Real: Replay(trans, nws)}
+	if len(trans) == 0 {
+		return report
+	}
+	st := s.Strategy
+	if st.StartDate.IsZero() {
+		st.StartDate = trans[0].Date
+	}
+	if st.EndDate.IsZero() {
+		st.EndDate = report.Real.Date
+	}
+	//实盘投入的本金按原日期存入，策略的买入优先使用这些资金，双方的投入相同
+	shadow := NewEngine(st, nws)
+	shadow.SetSchedule(Schedule{Amount: st.BasicAmount, StartDate: st.StartDate, Deposits: report.Real.Flows})
+	report.Shadow = shadow.Run()
+
+	used := make([]bool, len(trans))
+	for _, t := range report.Shadow.TransList {
+		kind := tradeKind(t.TransType)
+		if kind == 0 {
+			continue
+		}
+		k := s.match(trans, used, t)
+		if k < 0 {
+			missed := DeviationMissedBuy
+			if kind == TransSell {
+				missed = DeviationMissedSell
+			}
+			report.add(Deviation{Date: t.Date, Kind: missed, Expected: t.Amount, Cost: shadow.held(t)})
+			continue
+		}
+		used[k] = true
+		r := trans[k]
+		if diff := abs32(r.Amount - t.Amount); t.Amount > 0 && diff/t.Amount*100 > s.Tolerance {
+			report.add(Deviation{Date: r.Date, Kind: DeviationAmount, Expected: t.Amount, Actual: r.Amount, Cost: shadow.held(t) - shadow.held(r)})
+		}
+	}
+	for k, r := range trans {
+		kind := tradeKind(r.TransType)
+		if used[k] || kind == 0 {
+			continue
+		}
+		extra := DeviationExtraBuy
+		if kind == TransSell {
+			extra = DeviationEarlySell
+		}
+		report.add(Deviation{Date: r.Date, Kind: extra, Actual: r.Amount, Cost: -shadow.held(r)})
+	}
+	sort.SliceStable(report.Deviations, func(i, j int) bool {
+		return report.Deviations[i].Date.Before(report.Deviations[j].Date)
+	})
+	return report
+}
+
+//match 查找日期最接近且未匹配的同类实盘交易
+func (s Shadow) match(trans TransactionList, used []bool, t Transaction) int {
+	best, days := -1, s.Window+1
+	for k, r := range trans {
+		if used[k] || tradeKind(r.TransType) != tradeKind(t.TransType) {
+			continue
+		}
+		d := int(math.Abs(float64(DiffDays(r.Date, t.Date))))
+		if d < days {
+			best, days = k, d
+		}
+	}
+	return best
+}
+
+//tradeKind 按买入或卖出归类交易，其它交易返回0
+func tradeKind(t TransType) TransType {
+	switch t {
+	case TransFixed, TransAppend, TransContribute:
+		return TransFixed
+	case TransSell, TransWithdraw:
+		return TransSell
+	}
+	return 0
+}
+
+//held 交易相对不交易持有到期多得的金额，与收益归因的计算方式相同
+func (ctx *Engine) held(t Transaction) float32 {
+	shares := t.Shares
+	if shares == 0 && t.NAV > 0 {
+		shares = (t.Amount - t.TransFee) / t.NAV
+	}
+	value := shares * ctx.splitFactor(t.Date) * ctx.nav
+	if tradeKind(t.TransType) == TransSell {
+		return t.Amount - t.TransFee - value
+	}
+	return value - t.Amount
+}
+
+func (r *ShadowReport) add(d Deviation) {
+	r.Deviations = append(r.Deviations, d)
+	r.Cost += d.Cost
+}
+
+//Table 输出偏差明细表格
+func (r ShadowReport) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "实盘收益\t%.2f\t策略收益\t%.2f\t偏差损失\t%.2f\n", r.Real.Profit, r.Shadow.Profit, r.Cost)
+	fmt.Fprintln(tw, "日期\t类型\t建议金额\t实际金额\t损失")
+	for _, d := range r.Deviations {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%.2f\t%.2f\n", DateToString(d.Date), d.Kind, d.Expected, d.Actual, d.Cost)
+	}
+	return tw.Flush()
+}
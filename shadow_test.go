@@ -0,0 +1,73 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowCompare(t *testing.T) {
+	nws := waveNws("2020-01-01", 1000)
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	plan := NewEngine(s, nws).Run()
+	shadow := Shadow{Strategy: s}
+
+	report := shadow.Compare(plan.TransList, nws)
+	assert.Empty(t, report.Deviations)
+	assert.InDelta(t, report.Shadow.Profit, report.Real.Profit, 0.01)
+
+	var trans TransactionList
+	var missed, changed, sold bool
+	for _, tr := range plan.TransList {
+		switch {
+		case tr.TransType == TransAppend && !missed:
+			missed = true
+			continue
+		case tr.TransType == TransFixed && !changed:
+			changed = true
+			tr.Amount *= 2
+			tr.Shares *= 2
+		case tr.TransType == TransSell && !sold:
+			sold = true
+			extra := tr
+			extra.Date = tr.Date.AddDate(0, 0, -30)
+			extra.Amount, extra.Shares = 100, 100/extra.NAV
+			trans = append(trans, extra)
+		}
+		trans = append(trans, tr)
+	}
+	report = shadow.Compare(trans, nws)
+	kinds := map[DeviationKind]int{}
+	var cost float32
+	for _, d := range report.Deviations {
+		kinds[d.Kind]++
+		cost += d.Cost
+	}
+	assert.Equal(t, map[DeviationKind]int{DeviationMissedBuy: 1, DeviationAmount: 1, DeviationEarlySell: 1}, kinds)
+	assert.Equal(t, cost, report.Cost)
+	assert.NotZero(t, report.Cost)
+	for i := 1; i < len(report.Deviations); i++ {
+		assert.False(t, report.Deviations[i].Date.Before(report.Deviations[i-1].Date))
+	}
+}
+
+func TestShadowCompareAmount(t *testing.T) {
+	nws := waveNws("2020-01-01", 1000)
+	s := waveStrategy("2020-01-01", "2022-12-31")
+	plan := NewEngine(s, nws).Run()
+
+	//实盘每期定投的金额是策略的两倍，影子策略使用相同的本金
+	var trans TransactionList
+	for _, tr := range plan.TransList {
+		if tr.TransType == TransFixed {
+			tr.Amount *= 2
+			tr.Shares *= 2
+		}
+		trans = append(trans, tr)
+	}
+	report := Shadow{Strategy: s}.Compare(trans, nws)
+	assert.Greater(t, report.Real.Invest, plan.Invest)
+	assert.Equal(t, report.Real.Invest, report.Shadow.Invest)
+	//未使用的本金计入余额
+	assert.InDelta(t, report.Shadow.Value-report.Shadow.Shares*report.Shadow.Nav, report.Shadow.Balance, 0.1)
+}
@@ -1,6 +1,7 @@
 package backtesting
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -8,6 +9,7 @@ type (
 	//Strategy 策略
 	Strategy struct {
 		Code        string      //代码
+		Name        string      //策略名称，用于区分同一账户中的多个策略
 		BasicAmount float32     //投入基准金额
 		MinAmount   float32     //最小投入
 		MaxAmount   float32     //最大投入
@@ -16,16 +18,26 @@ type (
 		StartDate   time.Time   //开始时间
 		EndDate     time.Time   //截止时间
 		TransRate   float32     //交易费率
+		SellRate    float32     //赎回费率
 		CycleType   CycleType   //周期类型
-		CycleValue  int         //周期内值
+		CycleValue  int         //周期内值，按月及季度为第几日，按周及第N个星期几为星期几(1-7)，按周为0时同星期一
+		CycleDays   []int       //每月多个投资日，CycleMonthDays使用
+		CycleNth    int         //每月第几个星期几(1-5)，-1为最后一个，CycleNthWeekday使用
+		CycleDates  []time.Time //自定义投资日期，CycleDates使用
 		VolaDays    int         //统计涨跌幅天数
 		FixedMethod FixedMethod //定投方式
+		Disable     Rule        //关闭的规则，用于反事实对比
+		Order       OrderRule   //交易限制
+		Fee         FeeSchedule //份额类别费率
+		Roll        Roll        //投资日为非交易日时的处理方式，默认顺延
 	}
 
 	//FixedMethod 定投方式
 	FixedMethod int
 	//CycleType 周期类型
 	CycleType int
+	//Rule 策略规则
+	Rule int
 )
 
 const (
@@ -35,6 +47,16 @@ const (
 	CycleTowWeek CycleType = 2
 	//CycleWeek 每周
 	CycleWeek CycleType = 3
+	//CycleDay 每个交易日
+	CycleDay CycleType = 4
+	//CycleQuarter 每季度，季度首月的第CycleValue日
+	CycleQuarter CycleType = 5
+	//CycleMonthDays 每月CycleDays中的多个日期
+	CycleMonthDays CycleType = 6
+	//CycleNthWeekday 每月第CycleNth个星期CycleValue
+	CycleNthWeekday CycleType = 7
+	//CycleDates CycleDates中的自定义日期，错过的日期只在当月补投
+	CycleDates CycleType = 8
 )
 const (
 	//FixedInvest 定期定额
@@ -42,30 +64,173 @@ const (
 	//FloatInvest 不定期不定额
 	FloatInvest FixedMethod = 2
 )
+const (
+	//RuleAppend 追加买入
+	RuleAppend Rule = 1
+	//RuleSell 卖出
+	RuleSell Rule = 2
+	//RuleFloat 不定额投入，关闭后按基准金额定额投入
+	RuleFloat Rule = 4
+)
+
+//Disabled 规则是否已关闭
+func (s Strategy) Disabled(rule Rule) bool {
+	return s.Disable&rule != 0
+}
 
-//IsBuyDay 是否为投资日
-func (s Strategy) IsBuyDay(now time.Time, last interface{}) bool {
-	if last == nil {
-		return now.Day() >= s.CycleValue
+//Validate 检查投资周期的配置
+func (s Strategy) Validate() error {
+	weekday := func(min int) error {
+		if s.CycleValue < min || s.CycleValue > 7 {
+			return fmt.Errorf("星期应为%d-7，实际为%d", min, s.CycleValue)
+		}
+		return nil
+	}
+	monthday := func(day int) error {
+		if day < 1 || day > 31 {
+			return fmt.Errorf("日期应为1-31，实际为%d", day)
+		}
+		return nil
+	}
+	switch s.CycleType {
+	case CycleMonth, CycleQuarter:
+		return monthday(s.CycleValue)
+	case CycleWeek, CycleTowWeek:
+		//兼容旧配置，0同星期一
+		return weekday(0)
+	case CycleDay:
+		return nil
+	case CycleMonthDays:
+		if len(s.CycleDays) == 0 {
+			return fmt.Errorf("未设置每月投资日")
+		}
+		seen := map[int]bool{}
+		for _, day := range s.CycleDays {
+			if err := monthday(day); err != nil {
+				return err
+			}
+			if seen[day] {
+				return fmt.Errorf("每月投资日%d重复", day)
+			}
+			seen[day] = true
+		}
+		return nil
+	case CycleNthWeekday:
+		if s.CycleNth != -1 && (s.CycleNth < 1 || s.CycleNth > 5) {
+			return fmt.Errorf("第几个星期应为1-5或-1，实际为%d", s.CycleNth)
+		}
+		return weekday(1)
+	case CycleDates:
+		if len(s.CycleDates) == 0 {
+			return fmt.Errorf("未设置自定义投资日期")
+		}
+		return nil
 	}
-	var t = last.(time.Time)
-	now = ParseDate(DateToString(now))
-	t = ParseDate(DateToString(t))
-	if now.Weekday() == 0 || now.Weekday() == 6 {
+	return fmt.Errorf("未知的周期类型: %d", s.CycleType)
+}
+
+//IsBuyDay 是否为投资日，last为最近一次买入日期，未买入时为零值。
+//计划投资日按Roll调整为交易日，到达后且本期尚未买入时买入。调整后的投资日可能落在相邻周期，只在其所在的周期内有效
+func (s Strategy) IsBuyDay(now, last time.Time) bool {
+	if !IsTradingDay(now) {
 		return false
 	}
-	//判断定投月周期定投
-	if s.CycleType == CycleMonth {
-		return now.Sub(t).Hours()/24 > 25 && now.Day() >= s.CycleValue
+	now = civil(now)
+	if !last.IsZero() {
+		last = civil(last)
+		//两周定投间隔至少13天
+		if s.CycleType == CycleTowWeek && DiffDays(now, last) < 13 {
+			return false
+		}
+	}
+	for _, due := range s.scheduled(now) {
+		due = s.Roll.Adjust(due)
+		if due.IsZero() || due.After(now) || !s.samePeriod(due, now) {
+			continue
+		}
+		if last.IsZero() || last.Before(due) {
+			return true
+		}
 	}
-	var d = 0
-	//两周定投周期判断
-	if s.CycleType == CycleTowWeek {
-		d = 14
+	return false
+}
+
+//samePeriod 两个日期是否在同一个周期内
+func (s Strategy) samePeriod(a, b time.Time) bool {
+	switch s.CycleType {
+	case CycleDay:
+		return DiffDays(a, b) == 0
+	case CycleWeek, CycleTowWeek:
+		ay, aw := a.ISOWeek()
+		by, bw := b.ISOWeek()
+		return ay == by && aw == bw
+	case CycleQuarter:
+		return a.Year() == b.Year() && (a.Month()-1)/3 == (b.Month()-1)/3
 	}
-	//判断单周周期定投
-	if s.CycleType == CycleWeek {
-		d = 7
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
+
+//scheduled now所在周期及前后相邻周期的计划投资日，未按交易日调整
+func (s Strategy) scheduled(now time.Time) []time.Time {
+	var dates []time.Time
+	for period := -1; period <= 1; period++ {
+		switch s.CycleType {
+		case CycleDay:
+			return []time.Time{now}
+		case CycleDates:
+			return s.CycleDates
+		case CycleWeek, CycleTowWeek:
+			//0同星期一，默认顺延时即每周第一个交易日
+			day := s.CycleValue
+			if day == 0 {
+				day = 1
+			}
+			dates = append(dates, now.AddDate(0, 0, day-weekday(now)+period*7))
+		case CycleQuarter:
+			quarter := time.Month((int(now.Month())-1)/3*3 + 1)
+			dates = append(dates, monthDay(now.Year(), quarter+time.Month(period*3), s.CycleValue))
+		case CycleMonthDays:
+			for _, day := range s.CycleDays {
+				dates = append(dates, monthDay(now.Year(), now.Month()+time.Month(period), day))
+			}
+		case CycleNthWeekday:
+			dates = append(dates, nthWeekday(now.Year(), now.Month()+time.Month(period), s.CycleNth, s.CycleValue))
+		default:
+			dates = append(dates, monthDay(now.Year(), now.Month()+time.Month(period), s.CycleValue))
+		}
+	}
+	return dates
+}
+
+//weekday 星期几，星期一为1，星期日为7
+func weekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+//monthDay 当月第day日，超过当月天数时为月末
+func monthDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	if day < 1 {
+		day = 1
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+//nthWeekday 当月第n个星期wd，n为-1或当月没有第n个时为最后一个
+func nthWeekday(year int, month time.Month, n, wd int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	if n > 0 {
+		date := first.AddDate(0, 0, (wd-weekday(first)+7)%7+(n-1)*7)
+		if date.Month() == first.Month() {
+			return date
+		}
 	}
-	return now.Sub(t).Hours() >= float64(d-1)*24 && int(now.Weekday()) >= s.CycleValue
+	return last.AddDate(0, 0, -((weekday(last) - wd + 7) % 7))
 }
@@ -1,9 +1,12 @@
 package backtesting
 
 import (
+	"io/ioutil"
 	"log"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestStrategy(t *testing.T) {
@@ -12,7 +15,7 @@ func TestStrategy(t *testing.T) {
 		CycleValue: 1,
 	}
 	now := time.Now()
-	var last interface{}
+	var last time.Time
 	for i := 0; i < 90; i++ {
 		now = now.AddDate(0, 0, 1)
 		s.CycleType = CycleMonth
@@ -41,3 +44,91 @@ func TestStrategy(t *testing.T) {
 		}
 	}
 }
+
+func TestCycles(t *testing.T) {
+	buys := func(s Strategy, start, end string) []string {
+		var dates []string
+		var last time.Time
+		for now := ParseDate(start); !now.After(ParseDate(end)); now = now.AddDate(0, 0, 1) {
+			if s.IsBuyDay(now, last) {
+				last = now
+				dates = append(dates, DateToString(now))
+			}
+		}
+		return dates
+	}
+	assert.Len(t, buys(Strategy{CycleType: CycleDay}, "2021-02-01", "2021-02-28"), 15)
+	//元旦及国庆休市顺延
+	assert.Equal(t, []string{"2021-01-04", "2021-04-01", "2021-07-01", "2021-10-08"},
+		buys(Strategy{CycleType: CycleQuarter, CycleValue: 1}, "2021-01-01", "2021-12-31"))
+	assert.Equal(t, []string{"2021-01-05", "2021-01-20", "2021-02-05", "2021-02-22"},
+		buys(Strategy{CycleType: CycleMonthDays, CycleDays: []int{5, 20}}, "2021-01-01", "2021-02-28"))
+	assert.Equal(t, []string{"2021-01-15", "2021-02-19", "2021-03-19"},
+		buys(Strategy{CycleType: CycleNthWeekday, CycleNth: 3, CycleValue: 5}, "2021-01-01", "2021-03-31"))
+	assert.Equal(t, []string{"2021-01-29", "2021-02-26", "2021-03-26"},
+		buys(Strategy{CycleType: CycleNthWeekday, CycleNth: -1, CycleValue: 5}, "2021-01-01", "2021-03-31"))
+	dates := []time.Time{ParseDate("2021-01-12"), ParseDate("2021-02-12"), ParseDate("2021-03-03")}
+	assert.Equal(t, []string{"2021-01-12", "2021-02-18", "2021-03-03"},
+		buys(Strategy{CycleType: CycleDates, CycleDates: dates}, "2021-01-01", "2021-03-31"))
+	//按周为0时同星期一，国庆休市顺延到当周第一个交易日
+	assert.Equal(t, []string{"2021-09-27", "2021-10-08", "2021-10-11"},
+		buys(Strategy{CycleType: CycleWeek}, "2021-09-27", "2021-10-17"))
+	//开始时当期投资日已过，当期补投
+	assert.Equal(t, []string{"2021-01-25", "2021-02-05"},
+		buys(Strategy{CycleType: CycleMonthDays, CycleDays: []int{5, 20}}, "2021-01-23", "2021-02-10"))
+}
+
+func TestStrategyValidate(t *testing.T) {
+	assert.Nil(t, Strategy{CycleType: CycleMonth, CycleValue: 31}.Validate())
+	assert.Nil(t, Strategy{CycleType: CycleDay}.Validate())
+	assert.Nil(t, Strategy{CycleType: CycleNthWeekday, CycleNth: -1, CycleValue: 1}.Validate())
+	assert.NotNil(t, Strategy{}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleMonth, CycleValue: 32}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleWeek, CycleValue: 8}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleNthWeekday, CycleNth: 1}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleMonthDays}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleMonthDays, CycleDays: []int{5, 5}}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleNthWeekday, CycleNth: 6, CycleValue: 1}.Validate())
+	assert.NotNil(t, Strategy{CycleType: CycleDates}.Validate())
+
+	//周期配置错误的策略不进行交易
+	s := Strategy{
+		BasicAmount: 1000,
+		MinAmount:   100,
+		MaxAmount:   1000,
+		StartDate:   ParseDate("2021-01-01"),
+		EndDate:     ParseDate("2021-03-31"),
+		CycleType:   CycleWeek,
+		CycleValue:  9,
+		FixedMethod: FixedInvest,
+	}
+	r := NewEngine(s, flatNws("2021-01-01", 90, 1)).Run()
+	assert.NotNil(t, r.Err)
+	assert.Empty(t, r.TransList)
+	s.CycleValue = 5
+	r = NewEngine(s, flatNws("2021-01-01", 90, 1)).Run()
+	assert.Nil(t, r.Err)
+	assert.NotEmpty(t, r.TransList)
+
+	//组合及账户同样在运行前检查
+	bad := s
+	bad.CycleValue = 9
+	bad.Code = "bad"
+	pack := NewPackEngine(PackItemList{
+		{Engine: NewEngine(s, flatNws("2021-01-01", 90, 1)), TOF: Conservative, Precent: 50},
+		{Engine: NewEngine(bad, flatNws("2021-01-01", 90, 1)), TOF: Radical, Precent: 50},
+	}, ParseDate("2021-01-01"), 1000)
+	pack.SetEndDate(ParseDate("2021-03-31"))
+	pack.SetLogger(log.New(ioutil.Discard, "", 0))
+	pr := pack.Run()
+	assert.NotNil(t, pr.Err)
+	assert.Nil(t, pr.Items[0].Err)
+	assert.NotNil(t, pr.Items[1].Err)
+	assert.Empty(t, pr.TransList)
+	ar := NewAccount(flatNws("2021-01-01", 90, 1), s, bad).Run()
+	assert.NotNil(t, ar.Err)
+	assert.Nil(t, ar.Strategies[0].Err)
+	assert.NotNil(t, ar.Strategies[1].Err)
+	assert.Empty(t, ar.TransList)
+	assert.Nil(t, NewAccount(flatNws("2021-01-01", 90, 1), s).Run().Err)
+}
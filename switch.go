@@ -0,0 +1,186 @@
+package backtesting
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	//SwitchConfig 基金转换参数
+	SwitchConfig struct {
+		Rate float32 //转换补差费率(%)，按转出金额收取
+		Days int     //转入确认的交易日数，0为当日按转入基金净值确认
+	}
+	//switching 在途的转换
+	switching struct {
+		to      *PackItem //转入项目
+		amount  float32   //扣除费用后的转入金额
+		fee     float32   //转换费用
+		confirm time.Time //确认日期
+	}
+)
+
+//SetSwitch 设置基金转换参数。设置后调仓及激进型资金不足时，将直接从其它项目转换而不是卖出后再买入
+func (e *PackEngine) SetSwitch(conf SwitchConfig) {
+	e.switchConf = &conf
+}
+
+//Switch 将from项目的份额转换到to项目。返回转出记录，当日没有净值时返回nil
+func (e *PackEngine) Switch(from, to *PackItem, shares float32) *Transaction {
+	if e.switchConf == nil || from == to {
+		return nil
+	}
+	k, nw := from.nws.Today(e.now)
+	if k < 0 || shares <= 0 {
+		return nil
+	}
+	if shares > from.shares {
+		shares = from.shares
+	}
+	out := from.switchOut(shares, nw)
+	if out == nil {
+		return nil
+	}
+	from.refresh(nw)
+	e.record(from, out)
+	amount := -out.Amount
+	fee := ParseFloat32(fmt.Sprintf("%.2f", amount*e.switchConf.Rate/100))
+	e.switching = append(e.switching, switching{
+		to:      to,
+		amount:  amount - fee,
+		fee:     fee,
+		confirm: AddTradingDays(e.now, e.switchConf.Days),
+	})
+	//当日确认的转换，转入项目有净值时直接确认
+	if k, nw := to.nws.Today(e.now); k >= 0 {
+		e.confirm(to, nw)
+	}
+	return out
+}
+
+//confirm 确认转入项目在途的转换
+func (e *PackEngine) confirm(item *PackItem, nw NetWorth) {
+	var pending []switching
+	for _, s := range e.switching {
+		if s.to != item || DiffDays(nw.Date, s.confirm) < 0 {
+			pending = append(pending, s)
+			continue
+		}
+		if trans := item.switchIn(s.amount, s.fee, nw); trans != nil {
+			item.refresh(nw)
+			e.record(item, trans)
+		}
+	}
+	e.switching = pending
+}
+
+//transit 在途的转换金额
+func (e *PackEngine) transit() float32 {
+	var amount float32
+	for _, s := range e.switching {
+		amount += s.amount
+	}
+	return amount
+}
+
+//switchFor 从保守型项目转换资金给激进型项目，返回转出的金额
+func (e *PackEngine) switchFor(item *PackItem, amount float32) float32 {
+	if e.switchConf == nil || amount <= 0 {
+		return 0
+	}
+	var total float32
+	for k := range e.items {
+		from := &e.items[k]
+		if from.TOF != Conservative || from.shares <= 0 {
+			continue
+		}
+		i, fnw := from.nws.Today(e.now)
+		if i < 0 {
+			continue
+		}
+		shares := (amount - total) / fnw.NAV
+		if out := e.Switch(from, item, shares); out != nil {
+			total -= out.Amount
+		}
+		if total >= amount {
+			break
+		}
+	}
+	return total
+}
+
+//rebalance 按目标比例通过转换调整持仓，偏离不足总资产1%的不调整
+func (e *PackEngine) rebalance() {
+	if e.switchConf == nil {
+		return
+	}
+	var total float32
+	var precent int
+	values := make([]float32, len(e.items))
+	for k, item := range e.items {
+		i, nw := item.nws.Today(e.now)
+		if i < 0 {
+			return
+		}
+		values[k] = item.shares * nw.NAV
+		total += values[k]
+		precent += item.Precent
+	}
+	if total <= 0 || precent <= 0 {
+		return
+	}
+	diffs := make([]float32, len(e.items))
+	for k, item := range e.items {
+		diffs[k] = values[k] - total*float32(item.Precent)/float32(precent)
+	}
+	for from := range e.items {
+		for to := range e.items {
+			if diffs[from] <= total/100 || diffs[to] >= -total/100 {
+				continue
+			}
+			amount := diffs[from]
+			if -diffs[to] < amount {
+				amount = -diffs[to]
+			}
+			src := &e.items[from]
+			_, nw := src.nws.Today(e.now)
+			if out := e.Switch(src, &e.items[to], amount/nw.NAV); out != nil {
+				diffs[from] += out.Amount
+				diffs[to] -= out.Amount
+			}
+		}
+	}
+}
+
+//switchOut 转出份额
+func (ctx *Engine) switchOut(shares float32, nw NetWorth) *Transaction {
+	if shares = ctx.redeemable(shares, nw, TransSwitch); shares <= 0 {
+		return nil
+	}
+	amount := ParseFloat32(fmt.Sprintf("%.2f", nw.NAV*shares))
+	ctx.shares -= shares
+	trans := Transaction{
+		Date:      nw.Date,
+		Amount:    -amount,
+		NAV:       nw.NAV,
+		Shares:    -shares,
+		TransType: TransSwitch,
+		Decision:  ctx.decided(nw, TransSwitch),
+	}
+	ctx.balance += amount
+	ctx.trans.Append(trans)
+	return &trans
+}
+
+//switchIn 转入金额，转换费用已在转出时扣除。转入使用转出项目的资金，不计为新的投入，
+//余额减去转入金额及费用，为负表示使用了其他项目转出的资金
+func (ctx *Engine) switchIn(amount, fee float32, nw NetWorth) *Transaction {
+	ctx.balance -= amount + fee
+	trans := ctx.buy(nw, amount, 0, TransSwitch)
+	if trans == nil {
+		return nil
+	}
+	trans.TransFee = fee
+	ctx.trans[len(ctx.trans)-1].TransFee = fee
+	return trans
+}
@@ -0,0 +1,79 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func switchPack(days int) *PackEngine {
+	start := ParseDate("2021-01-04")
+	var items PackItemList
+	for k, nav := range []float32{1, 2} {
+		var nws NetWorthList
+		for i := 0; i < 10; i++ {
+			nws = append(nws, NetWorth{Date: start.AddDate(0, 0, i), NAV: nav})
+		}
+		tof := Conservative
+		if k == 1 {
+			tof = Radical
+		}
+		items = append(items, PackItem{
+			Engine:  NewEngine(Strategy{CycleType: CycleMonth, CycleValue: 1}, nws),
+			TOF:     tof,
+			Precent: 50,
+		})
+	}
+	e := NewPackEngine(items, start, 1000)
+	e.SetSwitch(SwitchConfig{Rate: 0.5, Days: days})
+	e.now = start
+	e.items[0].buy(e.items[0].nws[0], 1000, 0, TransFixed)
+	return e
+}
+
+func TestPackEngineSwitch(t *testing.T) {
+	e := switchPack(0)
+	out := e.Switch(&e.items[0], &e.items[1], 400)
+	assert.NotNil(t, out)
+	assert.Equal(t, TransSwitch, out.TransType)
+	assert.Equal(t, float32(-400), out.Shares)
+	assert.Equal(t, float32(600), e.items[0].shares)
+	//400元扣除0.5%转换费后按2元净值转入
+	assert.Equal(t, float32(199), e.items[1].shares)
+	in := e.items[1].trans.LastByType(TransSwitch)
+	assert.Equal(t, float32(2), in.TransFee)
+	assert.Len(t, e.trans, 2)
+	assert.Equal(t, float32(0), e.transit())
+	//转换只在项目间转移资金，不产生新的投入
+	assert.Equal(t, float32(0), e.items[1].invest)
+	assert.Equal(t, float32(400), e.items[0].balance)
+	assert.Equal(t, float32(-400), e.items[1].balance)
+}
+
+func TestPackEngineSwitchConfirm(t *testing.T) {
+	e := switchPack(2)
+	e.Switch(&e.items[0], &e.items[1], 400)
+	assert.Equal(t, float32(0), e.items[1].shares)
+	assert.Equal(t, float32(398), e.transit())
+	e.confirm(&e.items[1], e.items[1].nws[1])
+	assert.Equal(t, float32(0), e.items[1].shares)
+	e.confirm(&e.items[1], e.items[1].nws[2])
+	assert.Equal(t, float32(199), e.items[1].shares)
+	assert.Equal(t, float32(0), e.transit())
+
+	//确认天数按交易日计算，跳过周末
+	e = switchPack(5)
+	e.Switch(&e.items[0], &e.items[1], 400)
+	e.confirm(&e.items[1], e.items[1].nws[5])
+	assert.Equal(t, float32(0), e.items[1].shares)
+	e.confirm(&e.items[1], e.items[1].nws[7])
+	assert.Equal(t, float32(199), e.items[1].shares)
+}
+
+func TestPackEngineSwitchFor(t *testing.T) {
+	e := switchPack(0)
+	amount := e.switchFor(&e.items[1], 300)
+	assert.Equal(t, float32(300), amount)
+	assert.Equal(t, float32(700), e.items[0].shares)
+	assert.Equal(t, float32(149.25), e.items[1].shares)
+}
@@ -0,0 +1,270 @@
+package backtesting
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+//tradingDays 每年交易日数，用于年化参数换算
+const tradingDays = 252
+
+type (
+	//Model 日收益率模型。z为逐日的标准正态冲击，返回同样长度的日收益率(小数)
+	Model interface {
+		Returns(z []float64, r *rand.Rand) []float64
+	}
+	//GBM 几何布朗运动
+	GBM struct {
+		Drift float64 //年化收益率(%)
+		Vol   float64 //年化波动率(%)
+	}
+	//Regime 牛熊切换，持续天数服从几何分布，从牛市开始
+	Regime struct {
+		Bull     GBM
+		Bear     GBM
+		BullDays int //牛市平均持续交易日数
+		BearDays int //熊市平均持续交易日数
+	}
+	//MeanRevert 对数净值围绕均衡水平的均值回归(Ornstein-Uhlenbeck)
+	MeanRevert struct {
+		Mean  float64 //均衡水平，为初始净值的倍数，默认1
+		Speed float64 //年化回归速度，越大回归越快
+		Vol   float64 //年化波动率(%)
+	}
+	//Script 按分段描述的走势，如先跌40%再用三年涨回
+	Script []Segment
+	//Segment 走势分段，区间涨跌幅精确等于Change，Vol为叠加的波动
+	Segment struct {
+		Days   int     //交易日数
+		Change float64 //区间涨跌幅(%)
+		Vol    float64 //年化波动率(%)
+	}
+	//Event 分红或拆分，Day为第几个交易日
+	Event struct {
+		Day       int
+		Dividends float32 //每份分红
+		Splits    float32 //拆分比例
+	}
+	//Synthetic 模拟净值生成器，日期为Start起的工作日
+	Synthetic struct {
+		Start  time.Time
+		Days   int     //交易日数
+		NAV    float32 //初始净值，默认1
+		Seed   int64
+		Events []Event
+	}
+)
+
+//Returns 几何布朗运动的日收益率
+func (m GBM) Returns(z []float64, r *rand.Rand) []float64 {
+	items := make([]float64, len(z))
+	for k := range z {
+		items[k] = m.next(z[k])
+	}
+	return items
+}
+
+//next 单日收益率
+func (m GBM) next(z float64) float64 {
+	mu, sigma := m.Drift/100, m.Vol/100
+	dt := 1.0 / tradingDays
+	return math.Exp((mu-sigma*sigma/2)*dt+sigma*math.Sqrt(dt)*z) - 1
+}
+
+//Returns 牛熊切换的日收益率
+func (m Regime) Returns(z []float64, r *rand.Rand) []float64 {
+	items := make([]float64, len(z))
+	bull := true
+	for k := range z {
+		if bull {
+			items[k] = m.Bull.next(z[k])
+			bull = !switched(r, m.BullDays)
+		} else {
+			items[k] = m.Bear.next(z[k])
+			bull = switched(r, m.BearDays)
+		}
+	}
+	return items
+}
+
+//switched 平均持续days天的状态在当天结束
+func switched(r *rand.Rand, days int) bool {
+	return days > 0 && r.Float64() < 1/float64(days)
+}
+
+//Returns 均值回归的日收益率
+func (m MeanRevert) Returns(z []float64, r *rand.Rand) []float64 {
+	mean := m.Mean
+	if mean <= 0 {
+		mean = 1
+	}
+	mu, sigma := math.Log(mean), m.Vol/100
+	dt := 1.0 / tradingDays
+	items := make([]float64, len(z))
+	x := 0.0
+	for k := range z {
+		last := x
+		x += m.Speed*(mu-x)*dt + sigma*math.Sqrt(dt)*z[k]
+		items[k] = math.Exp(x-last) - 1
+	}
+	return items
+}
+
+//Returns 分段走势的日收益率，超出脚本的天数净值不变。
+//分段内的波动去除均值，保证区间涨跌幅不受冲击影响
+func (m Script) Returns(z []float64, r *rand.Rand) []float64 {
+	items := make([]float64, len(z))
+	i := 0
+	for _, seg := range m {
+		if seg.Days <= 0 {
+			continue
+		}
+		end := i + seg.Days
+		if end > len(z) {
+			end = len(z)
+		}
+		if i >= end {
+			break
+		}
+		drift := math.Log(1+seg.Change/100) / float64(seg.Days)
+		shock := seg.Vol / 100 * math.Sqrt(1.0/tradingDays)
+		var mean float64
+		for k := i; k < end; k++ {
+			mean += z[k]
+		}
+		mean /= float64(end - i)
+		for k := i; k < end; k++ {
+			items[k] = math.Exp(drift+shock*(z[k]-mean)) - 1
+		}
+		i = end
+	}
+	return items
+}
+
+//Generate 按模型生成净值
+func (s Synthetic) Generate(m Model) NetWorthList {
+	r := rand.New(rand.NewSource(s.Seed))
+	return s.build(m.Returns(s.shocks(r), r))
+}
+
+//Correlated 按相关系数矩阵生成多只基金的净值，日期相同，可直接用于组合回测
+func (s Synthetic) Correlated(models []Model, corr [][]float64) ([]NetWorthList, error) {
+	n := len(models)
+	if len(corr) != n {
+		return nil, fmt.Errorf("相关系数矩阵为%d行，模型为%d个", len(corr), n)
+	}
+	l, err := cholesky(corr)
+	if err != nil {
+		return nil, err
+	}
+	r := rand.New(rand.NewSource(s.Seed))
+	shocks := make([][]float64, n)
+	for k := range shocks {
+		shocks[k] = s.shocks(r)
+	}
+	items := make([]NetWorthList, n)
+	for k, m := range models {
+		z := make([]float64, len(shocks[k]))
+		for d := range z {
+			for j := 0; j <= k; j++ {
+				z[d] += l[k][j] * shocks[j][d]
+			}
+		}
+		items[k] = s.build(m.Returns(z, r))
+	}
+	return items, nil
+}
+
+//shocks 首日之后每个交易日的标准正态冲击
+func (s Synthetic) shocks(r *rand.Rand) []float64 {
+	if s.Days <= 1 {
+		return nil
+	}
+	z := make([]float64, s.Days-1)
+	for k := range z {
+		z[k] = r.NormFloat64()
+	}
+	return z
+}
+
+//build 将日收益率转为净值，分红和拆分在当日收益之后除权
+func (s Synthetic) build(returns []float64) NetWorthList {
+	if s.Days <= 0 {
+		return nil
+	}
+	nav := float64(s.NAV)
+	if nav <= 0 {
+		nav = 1
+	}
+	events := map[int]Event{}
+	for _, e := range s.Events {
+		events[e.Day] = e
+	}
+	cnav := nav
+	items := make(NetWorthList, 0, s.Days)
+	date := s.Start
+	for k := 0; k < s.Days; k++ {
+		for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			date = date.AddDate(0, 0, 1)
+		}
+		var roc float64
+		if k > 0 {
+			roc = returns[k-1]
+		}
+		nav *= 1 + roc
+		cnav *= 1 + roc
+		e := events[k]
+		if e.Dividends > 0 && float64(e.Dividends) < nav {
+			nav -= float64(e.Dividends)
+		} else {
+			e.Dividends = 0
+		}
+		if e.Splits > 0 {
+			nav /= float64(e.Splits)
+		}
+		items = append(items, NetWorth{
+			Date:      date,
+			NAV:       float32(nav),
+			CNAV:      float32(cnav),
+			ROC:       float32(roc * 100),
+			Dividends: e.Dividends,
+			Splits:    e.Splits,
+		})
+		date = date.AddDate(0, 0, 1)
+	}
+	return items
+}
+
+//cholesky 相关系数矩阵的Cholesky分解，返回下三角矩阵
+func cholesky(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	l := make([][]float64, n)
+	for i := range l {
+		if len(a[i]) != n {
+			return nil, fmt.Errorf("相关系数矩阵第%d行长度为%d", i+1, len(a[i]))
+		}
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			if a[i][j] != a[j][i] {
+				return nil, fmt.Errorf("相关系数矩阵不对称")
+			}
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, fmt.Errorf("相关系数矩阵不是正定矩阵")
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,83 @@
+package backtesting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntheticGBM(t *testing.T) {
+	s := Synthetic{Start: ParseDate("2020-01-01"), Days: 500, Seed: 1}
+	nws := s.Generate(GBM{Drift: 8, Vol: 20})
+	assert.Len(t, nws, 500)
+	assert.Equal(t, float32(1), nws[0].NAV)
+	assert.Equal(t, nws, s.Generate(GBM{Drift: 8, Vol: 20}))
+	for _, nw := range nws {
+		assert.NotContains(t, []string{"Saturday", "Sunday"}, nw.Date.Weekday().String())
+	}
+	for k := 1; k < len(nws); k++ {
+		roc := (nws[k].NAV/nws[k-1].NAV - 1) * 100
+		assert.InDelta(t, roc, nws[k].ROC, 0.01)
+	}
+	s.Seed = 2
+	assert.NotEqual(t, nws[499].NAV, s.Generate(GBM{Drift: 8, Vol: 20})[499].NAV)
+}
+
+func TestSyntheticScript(t *testing.T) {
+	s := Synthetic{Start: ParseDate("2020-01-01"), Days: 1 + 60 + 750 + 20, NAV: 2, Seed: 1}
+	//先跌40%，再用三年涨回
+	nws := s.Generate(Script{{Days: 60, Change: -40, Vol: 30}, {Days: 750, Change: 100.0/0.6 - 100, Vol: 20}})
+	assert.InDelta(t, 1.2, nws[60].NAV, 0.001)
+	assert.InDelta(t, 2, nws[810].NAV, 0.001)
+	//超出脚本后不变
+	assert.Equal(t, nws[810].NAV, nws[830].NAV)
+}
+
+func TestSyntheticRegimeAndMeanRevert(t *testing.T) {
+	s := Synthetic{Start: ParseDate("2020-01-01"), Days: 2000, Seed: 3}
+	nws := s.Generate(Regime{Bull: GBM{Drift: 30, Vol: 15}, Bear: GBM{Drift: -30, Vol: 30}, BullDays: 250, BearDays: 120})
+	assert.Len(t, nws, 2000)
+	nws = s.Generate(MeanRevert{Mean: 1.5, Speed: 5, Vol: 10})
+	var sum float64
+	for _, nw := range nws[1000:] {
+		sum += float64(nw.NAV)
+	}
+	assert.InDelta(t, 1.5, sum/1000, 0.15)
+}
+
+func TestSyntheticEvents(t *testing.T) {
+	s := Synthetic{Start: ParseDate("2020-01-01"), Days: 300, Seed: 1, Events: []Event{{Day: 100, Dividends: 0.1}, {Day: 200, Splits: 2}}}
+	nws := s.Generate(GBM{Drift: 0, Vol: 0})
+	assert.Equal(t, float32(0.1), nws[100].Dividends)
+	assert.InDelta(t, 0.9, nws[100].NAV, 0.0001)
+	assert.Equal(t, float32(0), nws[100].ROC)
+	assert.Equal(t, float32(2), nws[200].Splits)
+	assert.InDelta(t, 0.45, nws[200].NAV, 0.0001)
+	assert.Equal(t, float32(1), nws[299].CNAV)
+
+	//分红再投资与拆分后市值不变
+	e := NewEngine(Strategy{StartDate: nws[0].Date, EndDate: nws[299].Date, BasicAmount: 1000, CycleType: CycleMonth, CycleValue: 1}, nws)
+	result := e.Run()
+	assert.InDelta(t, result.Invest, result.Value+result.Balance, 1)
+}
+
+func TestSyntheticCorrelated(t *testing.T) {
+	s := Synthetic{Start: ParseDate("2020-01-01"), Days: 3000, Seed: 1}
+	items, err := s.Correlated([]Model{GBM{Drift: 8, Vol: 25}, GBM{Drift: 4, Vol: 5}}, [][]float64{{1, 0.8}, {0.8, 1}})
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, items[0][2999].Date, items[1][2999].Date)
+	var sx, sy, sxx, syy, sxy, n float64
+	for k := 1; k < 3000; k++ {
+		x, y := float64(items[0][k].ROC), float64(items[1][k].ROC)
+		sx, sy, sxx, syy, sxy, n = sx+x, sy+y, sxx+x*x, syy+y*y, sxy+x*y, n+1
+	}
+	corr := (sxy/n - sx/n*sy/n) / math.Sqrt((sxx/n-sx/n*sx/n)*(syy/n-sy/n*sy/n))
+	assert.InDelta(t, 0.8, corr, 0.05)
+
+	_, err = s.Correlated([]Model{GBM{}, GBM{}}, [][]float64{{1, 1.2}, {1.2, 1}})
+	assert.NotNil(t, err)
+	_, err = s.Correlated([]Model{GBM{}}, [][]float64{{1, 0}, {0, 1}})
+	assert.NotNil(t, err)
+}
@@ -19,6 +19,8 @@ import (
 var (
 	navAPI       = "http://fund.eastmoney.com/f10/F10DataApi.aspx"
 	dividendsAPI = "http://fundf10.eastmoney.com/fhsp_%s.html"
+	profileAPI   = "http://fundf10.eastmoney.com/jbgk_%s.html"
+	managerAPI   = "http://fundf10.eastmoney.com/jjjl_%s.html"
 )
 
 //Basic 基金信息
@@ -37,8 +39,65 @@ var TianTian = tianTian{}
 
 //GetHistories 获取历史净值数据
 func (tt *tianTian) GetHistories(code string, sdate string, edate string) []NetWorth {
-	var page = 1
 	items := make([]NetWorth, 0)
+	tt.histories(code, sdate, edate, func(money bool, s *goquery.Selection) {
+		if money {
+			yield := ParseFloat32(s.Find("td").Eq(1).Text())
+			items = append(items, NetWorth{
+				Date:   ParseDate(s.Find("td").Eq(0).Text()),
+				NAV:    1,
+				CNAV:   1,
+				ROC:    yield / 100,
+				Yield:  yield,
+				Yield7: ParseFloat32(strings.Replace(s.Find("td").Eq(2).Text(), "%", "", -1)),
+			})
+			return
+		}
+		rate := strings.Replace(s.Find("td").Eq(3).Text(), "%", "", -1)
+		if rate == "" {
+			rate = "0"
+		}
+		navs := s.Find("td").Eq(1).Text()
+		cnavs := s.Find("td").Eq(2).Text()
+		if cnavs == "" {
+			cnavs = navs
+		}
+		last := NetWorth{
+			Date:   ParseDate(s.Find("td").Eq(0).Text()),
+			NAV:    ParseFloat32(navs),
+			CNAV:   ParseFloat32(cnavs),
+			ROC:    ParseFloat32(rate),
+			Splits: tt.resolveSplits(strings.TrimSpace(s.Find("td").Eq(6).Text())),
+		}
+		items = append(items, last)
+	})
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return tt.fillDividends(code, items)
+}
+
+//GetAvailability 获取历史申购、赎回状态。净值表只有状态没有限额，限大额的上限需通过WithLimit设置
+func (tt *tianTian) GetAvailability(code string, sdate string, edate string) AvailabilityList {
+	items := make(AvailabilityList, 0)
+	tt.histories(code, sdate, edate, func(money bool, s *goquery.Selection) {
+		//货币基金少一列
+		col := 4
+		if money {
+			col = 3
+		}
+		items = append(items, Availability{
+			Date:      ParseDate(s.Find("td").Eq(0).Text()),
+			Subscribe: ParseStatus(strings.TrimSpace(s.Find("td").Eq(col).Text())),
+			Redeem:    ParseStatus(strings.TrimSpace(s.Find("td").Eq(col + 1).Text())),
+		})
+	})
+	return items.compact()
+}
+
+//histories 分页获取历史净值表，逐行回调，money表示是否为货币基金
+func (tt *tianTian) histories(code string, sdate string, edate string, fn func(money bool, s *goquery.Selection)) {
+	var page = 1
 	for {
 		query := map[string]string{}
 		query["type"] = "lsjz"
@@ -56,27 +115,13 @@ func (tt *tianTian) GetHistories(code string, sdate string, edate string) []NetW
 			log.Panic("解析失败", err, dom)
 		}
 		trs := dom.Find("table tbody tr")
+		//货币基金的列为 日期 每万份收益 七日年化收益率
+		money := strings.Contains(dom.Find("table thead th").Eq(1).Text(), "万份收益")
 		trs.Each(func(i int, s *goquery.Selection) {
 			if s.Text() == "暂无数据!" {
 				return
 			}
-			rate := strings.Replace(s.Find("td").Eq(3).Text(), "%", "", -1)
-			if rate == "" {
-				rate = "0"
-			}
-			navs := s.Find("td").Eq(1).Text()
-			cnavs := s.Find("td").Eq(2).Text()
-			if cnavs == "" {
-				cnavs = navs
-			}
-			last := NetWorth{
-				Date:   ParseDate(s.Find("td").Eq(0).Text()),
-				NAV:    ParseFloat32(navs),
-				CNAV:   ParseFloat32(cnavs),
-				ROC:    ParseFloat32(rate),
-				Splits: tt.resolveSplits(strings.TrimSpace(s.Find("td").Eq(6).Text())),
-			}
-			items = append(items, last)
+			fn(money, s)
 		})
 
 		if trs.Length() < 40 {
@@ -84,10 +129,6 @@ func (tt *tianTian) GetHistories(code string, sdate string, edate string) []NetW
 		}
 		page++
 	}
-	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
-		items[i], items[j] = items[j], items[i]
-	}
-	return tt.fillDividends(code, items)
 }
 
 //获取份额拆分信息
@@ -165,6 +206,50 @@ func (tt *tianTian) GetBasic(code string) Basic {
 	}
 }
 
+//GetProfile 获取基金概况及现任基金经理任职日期
+func (tt *tianTian) GetProfile(code string) (Profile, error) {
+	profile := Profile{Basic: Basic{Code: code}}
+	dom, err := tt.document(fmt.Sprintf(profileAPI, code))
+	if err != nil {
+		return profile, err
+	}
+	dom.Find("table.info th").Each(func(i int, s *goquery.Selection) {
+		value := strings.TrimSpace(s.Next().Text())
+		switch strings.TrimSpace(s.Text()) {
+		case "基金简称":
+			profile.Name = value
+		case "基金类型":
+			profile.Type = value
+		case "成立日期/规模":
+			if d := FindAllString("\\d{4}年\\d{2}月\\d{2}日", value); len(d) > 0 {
+				profile.Inception, _ = time.Parse("2006年01月02日", d[0])
+			}
+		case "资产规模":
+			if v := FindAllString("(\\d+\\.?\\d*)", value); len(v) > 0 {
+				profile.AUM, _ = strconv.ParseFloat(v[0], 64)
+			}
+		}
+	})
+	dom, err = tt.document(fmt.Sprintf(managerAPI, code))
+	if err != nil {
+		return profile, err
+	}
+	//第一行为现任基金经理
+	tds := dom.Find("table.jloff tbody tr").First().Find("td")
+	profile.ManagerSince = ParseDate(strings.TrimSpace(tds.Eq(0).Text()))
+	profile.Manager = strings.TrimSpace(tds.Eq(2).Text())
+	return profile, nil
+}
+
+func (tt *tianTian) document(u string) (*goquery.Document, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
 func (tt *tianTian) GetFundList(page, size int) ([]Basic, error) {
 	query := map[string]string{
 		"op":   "dy",
@@ -1,19 +1,21 @@
 package backtesting
 
 import (
+	"sort"
 	"time"
 )
 
 type (
 	//Transaction 交易记录
 	Transaction struct {
-		Date      time.Time              //日期
-		Amount    float32                //交易金额
-		NAV       float32                //净值
-		TransFee  float32                //交易费用
-		Shares    float32                //交易份额
-		TransType TransType              //交易类型
-		Args      map[string]interface{} //买入时的参数
+		Date      time.Time //日期
+		Amount    float32   //交易金额
+		NAV       float32   //净值
+		TransFee  float32   //交易费用，取出时包含税费
+		Shares    float32   //交易份额
+		TransType TransType //交易类型
+		Decision  *Decision //交易决策
+		Strategy  string    //策略名称，组合账户中区分交易来源
 	}
 	//TransactionList 交易记录
 	TransactionList []Transaction
@@ -31,6 +33,12 @@ const (
 	TransAppend TransType = 3
 	//TransSell 卖出
 	TransSell TransType = 4
+	//TransSwitch 基金转换，转出记录的份额和金额为负数
+	TransSwitch TransType = 5
+	//TransContribute 投入计划中一次性投入的买入，不计为定投
+	TransContribute TransType = 6
+	//TransWithdraw 取出
+	TransWithdraw TransType = 7
 )
 
 //LastSell 最后一次卖出记录
@@ -59,13 +67,15 @@ func (items *TransactionList) Append(t Transaction) {
 	*items = append(*items, t)
 }
 
-//Today 获取指定当天的交易
+//Today 获取指定当天的交易。交易记录按日期升序排列，按日期二分查找
 func (items TransactionList) Today(today time.Time) *Transaction {
-	for _, item := range items {
-		diff := today.Sub(item.Date)
-		if diff.Hours() < 24 && diff.Hours() >= 0 {
-			return &item
-		}
+	from := today.Add(-24 * time.Hour)
+	k := sort.Search(len(items), func(i int) bool {
+		return items[i].Date.After(from)
+	})
+	if k < len(items) && !items[k].Date.After(today) {
+		item := items[k]
+		return &item
 	}
 	return nil
 }
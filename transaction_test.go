@@ -30,3 +30,16 @@ func TestTransactionList(t *testing.T) {
 	assert.Equal(t, last.Amount, float32(800))
 	assert.Len(t, items, 2)
 }
+
+func TestTransactionListToday(t *testing.T) {
+	items := TransactionList{
+		{Date: ParseDate("2021-01-04"), Amount: 100},
+		{Date: ParseDate("2021-01-06"), Amount: 200},
+		{Date: ParseDate("2021-01-06"), Amount: 300},
+		{Date: ParseDate("2021-01-08"), Amount: 400},
+	}
+	assert.Equal(t, float32(200), items.Today(ParseDate("2021-01-06")).Amount)
+	assert.Equal(t, float32(400), items.Today(ParseDate("2021-01-08").Add(12*time.Hour)).Amount)
+	assert.Nil(t, items.Today(ParseDate("2021-01-05")))
+	assert.Nil(t, items.Today(ParseDate("2021-01-09")))
+}
@@ -3,6 +3,7 @@ package backtesting
 import (
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -39,7 +40,30 @@ func FindAllString(rex string, str string) []string {
 	return p.FindAllString(str, -1)
 }
 
-//DiffDays 判断相差天数
+//DiffDays 判断相差天数，按上海时区的日期计算
 func DiffDays(t1, t2 time.Time) int {
-	return int(t1.Sub(t2).Hours() / 24)
+	return int(civil(t1).Sub(civil(t2)).Hours() / 24)
+}
+
+//parallel 使用workers个协程并发执行fn(0)至fn(n-1)
+func parallel(n, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 }
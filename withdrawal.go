@@ -0,0 +1,305 @@
+package backtesting
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	//WithdrawRule 取出规则
+	WithdrawRule int
+	//Withdrawal 取出计划。设置后引擎进入取出模式，不再按策略定投及卖出
+	Withdrawal struct {
+		Rule      WithdrawRule //取出规则
+		Initial   float32      //初始资金，开始日一次性投入
+		Amount    float32      //每月取出金额，固定金额规则使用
+		Rate      float32      //年取出比例(%)，固定比例及护栏规则使用
+		Day       int          //每月取出日，超过当月最后一个交易日时在最后一个交易日取出
+		Inflation float32      //固定金额规则每年上调比例(%)
+		Upper     float32      //护栏上限(%)，当前取出率高于初始取出率的(1+Upper%)时削减取出金额
+		Lower     float32      //护栏下限(%)，当前取出率低于初始取出率的(1-Lower%)时提高取出金额
+		Adjust    float32      //护栏调整幅度(%)
+		TaxRate   float32      //收益部分的税率(%)
+	}
+	//withdrawing 取出状态
+	withdrawing struct {
+		annual    float32   //当前年度取出金额
+		month     time.Time //最近一次取出的月份
+		year      time.Time //最近一次调整年度金额的日期
+		withdrawn float32   //累计取出金额
+		depleted  time.Time //资金耗尽日期
+	}
+)
+
+const (
+	//WithdrawFixed 固定金额
+	WithdrawFixed WithdrawRule = 1
+	//WithdrawPercent 固定比例
+	WithdrawPercent WithdrawRule = 2
+	//WithdrawGuardrail 护栏规则
+	WithdrawGuardrail WithdrawRule = 3
+)
+
+//SetWithdrawal 设置取出计划，引擎进入取出模式
+func (ctx *Engine) SetWithdrawal(w Withdrawal) {
+	ctx.withdraw = &w
+}
+
+//decumulate 取出模式下的当日操作
+func (ctx *Engine) decumulate(nw NetWorth) {
+	if ctx.withdraw.Initial > 0 && len(ctx.trans) == 0 {
+		ctx.fixed(ctx.withdraw.Initial, nw)
+	}
+	if nw.Dividends > 0 {
+		ctx.dividends(nw)
+	}
+	if !ctx.isWithdrawDay(nw.Date) {
+		return
+	}
+	amount := ctx.withdraw.monthly(&ctx.drawing, nw.Date, ctx.shares*nw.NAV+ctx.balance)
+	if ctx.withdrawAmount(amount, nw) {
+		ctx.drawing.month = nw.Date
+	}
+}
+
+//isWithdrawDay 每月取出日及之后的交易日，当月尚未取出
+func (ctx *Engine) isWithdrawDay(date time.Time) bool {
+	return isWithdrawDay(ctx.withdraw, ctx.drawing, date)
+}
+
+func isWithdrawDay(w *Withdrawal, drawing withdrawing, date time.Time) bool {
+	if civil(date).Before(w.due(date)) {
+		return false
+	}
+	return drawing.month.IsZero() || drawing.month.Year() != date.Year() || drawing.month.Month() != date.Month()
+}
+
+//due 当月的取出日，超过当月最后一个交易日时为最后一个交易日
+func (w *Withdrawal) due(date time.Time) time.Time {
+	date = civil(date)
+	day := monthDay(date.Year(), date.Month(), w.Day)
+	next := time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	if last := PrevTradingDay(next); last.Before(day) && last.Month() == date.Month() {
+		return last
+	}
+	return day
+}
+
+//monthly 计算当月取出金额，value为当前资产总值
+func (w *Withdrawal) monthly(drawing *withdrawing, date time.Time, value float32) float32 {
+	if !drawing.year.IsZero() && DiffDays(date, drawing.year.AddDate(1, 0, 0)) < 0 {
+		if w.Rule == WithdrawPercent {
+			return value * w.Rate / 100 / 12
+		}
+		return drawing.annual / 12
+	}
+	//每年调整一次取出金额
+	first := drawing.year.IsZero()
+	drawing.year = date
+	switch w.Rule {
+	case WithdrawFixed:
+		if first {
+			drawing.annual = w.Amount * 12
+		} else {
+			drawing.annual *= 1 + w.Inflation/100
+		}
+	case WithdrawPercent:
+		return value * w.Rate / 100 / 12
+	case WithdrawGuardrail:
+		if first {
+			drawing.annual = value * w.Rate / 100
+		} else if value > 0 {
+			rate := drawing.annual / value * 100
+			if rate > w.Rate*(1+w.Upper/100) {
+				drawing.annual *= 1 - w.Adjust/100
+			} else if rate < w.Rate*(1-w.Lower/100) {
+				drawing.annual *= 1 + w.Adjust/100
+			}
+		}
+	}
+	return drawing.annual / 12
+}
+
+//withdrawAmount 取出指定的到手金额，优先使用余额，不足时卖出份额并扣除赎回费和税费。
+//持有份额却因暂停赎回等原因未能卖出时不取出，返回false，之后的交易日再取出
+func (ctx *Engine) withdrawAmount(amount float32, nw NetWorth) bool {
+	if amount <= 0 {
+		return true
+	}
+	if ctx.balance >= amount {
+		ctx.balance -= amount
+		ctx.drawing.withdrawn += amount
+		return true
+	}
+	need := amount - ctx.balance
+	trans := ctx.withdrawShares(need, ctx.withdraw.TaxRate, nw)
+	if trans == nil && ctx.shares > 0 {
+		return false
+	}
+	ctx.drawing.withdrawn += ctx.balance
+	ctx.balance = 0
+	var got float32
+	if trans != nil {
+		got = trans.Amount - trans.TransFee
+		ctx.drawing.withdrawn += got
+	}
+	//不足以支付当月取出金额时视为耗尽
+	if got < need*0.99 && ctx.drawing.depleted.IsZero() {
+		ctx.drawing.depleted = nw.Date
+	}
+	return true
+}
+
+//withdrawShares 卖出份额取出指定的到手金额，扣除赎回费及收益部分的税费。份额不足时全部卖出
+func (ctx *Engine) withdrawShares(amount, taxRate float32, nw NetWorth) *Transaction {
+	//每份到手金额 = 净值 - 赎回费 - 收益部分的税费
+	cost := ctx.avgCost()
+	net := nw.NAV * (1 - ctx.sellRate(amount/nw.NAV, nw)/100)
+	if nw.NAV > cost {
+		net -= (nw.NAV - cost) * taxRate / 100
+	}
+	shares := ctx.redeemable(amount/net, nw, TransWithdraw)
+	if shares <= 0 {
+		return nil
+	}
+	gross := nw.NAV * shares
+	fee := ctx.redeemFee(shares, nw)
+	if nw.NAV > cost {
+		fee += (nw.NAV - cost) * shares * taxRate / 100
+	}
+	fee = ParseFloat32(fmt.Sprintf("%.2f", fee))
+	ctx.shares -= shares
+	trans := Transaction{
+		Date:      nw.Date,
+		Amount:    gross,
+		NAV:       nw.NAV,
+		TransFee:  fee,
+		Shares:    shares,
+		TransType: TransWithdraw,
+		Decision:  ctx.decided(nw, TransWithdraw),
+	}
+	ctx.trans.Append(trans)
+	return &trans
+}
+
+//avgCost 持仓的平均成本
+func (ctx *Engine) avgCost() float32 {
+	var cost, shares float32
+	for _, t := range ctx.trans {
+		switch t.TransType {
+		case TransFixed, TransAppend, TransContribute, TransDividends:
+			cost += t.Amount
+			shares += t.Shares
+		case TransSwitch:
+			if t.Shares > 0 {
+				cost += t.Amount
+				shares += t.Shares
+			} else if shares > 0 {
+				cost += cost / shares * t.Shares
+				shares += t.Shares
+			}
+		case TransSell, TransWithdraw:
+			if shares > 0 {
+				cost -= cost / shares * t.Shares
+				shares -= t.Shares
+			}
+		}
+	}
+	if shares <= 0 {
+		return 0
+	}
+	return cost / shares
+}
+
+//Depletion 在多条模拟路径上运行取出计划，返回资金耗尽的概率及各路径结果
+func Depletion(s Strategy, w Withdrawal, paths []NetWorthList) (float64, []Result) {
+	results := make([]Result, 0, len(paths))
+	depleted := 0
+	for _, nws := range paths {
+		e := NewEngine(s, nws)
+		e.SetWithdrawal(w)
+		result := e.Run()
+		if !result.Depleted.IsZero() {
+			depleted++
+		}
+		results = append(results, result)
+	}
+	if len(paths) == 0 {
+		return 0, results
+	}
+	return float64(depleted) / float64(len(paths)), results
+}
+
+//SetWithdrawal 设置组合的取出计划，组合进入取出模式，不再按月投入
+func (e *PackEngine) SetWithdrawal(w Withdrawal) {
+	e.withdraw = &w
+}
+
+//decumulate 组合取出，优先使用余额，不足时按持仓价值比例从各项目卖出
+func (e *PackEngine) decumulate() {
+	if e.withdraw.Initial > 0 && e.invest == 0 {
+		e.AddBanlance(e.withdraw.Initial)
+	}
+	//非交易日不取出
+	var total float32
+	trading := false
+	nws := make([]NetWorth, len(e.items))
+	for k, item := range e.items {
+		i, nw := item.nws.Today(e.now)
+		if i < 0 {
+			continue
+		}
+		trading = true
+		nws[k] = nw
+		total += item.shares * nw.NAV
+	}
+	if !trading || !isWithdrawDay(e.withdraw, e.drawing, e.now) {
+		return
+	}
+	e.refresh()
+	amount := e.withdraw.monthly(&e.drawing, e.now, e.value)
+	if amount <= 0 {
+		e.drawing.month = e.now
+		return
+	}
+	if e.balance >= amount {
+		e.balance -= amount
+		e.drawing.withdrawn += amount
+		e.drawing.month = e.now
+		return
+	}
+	need := amount - e.balance
+	var got float32
+	if total > 0 {
+		if got = e.withdrawItems(need, total, nws); got <= 0 {
+			//持有份额却全部未能卖出时，之后的交易日再取出
+			return
+		}
+	}
+	e.drawing.month = e.now
+	e.drawing.withdrawn += e.balance
+	e.balance = 0
+	e.drawing.withdrawn += got
+	if got < need*0.99 && e.drawing.depleted.IsZero() {
+		e.drawing.depleted = e.now
+	}
+}
+
+//withdrawItems 按持仓价值比例从各项目卖出need的到手金额，返回实际到手的金额
+func (e *PackEngine) withdrawItems(need, total float32, nws []NetWorth) float32 {
+	var got float32
+	for k := range e.items {
+		item := &e.items[k]
+		if nws[k].Date.IsZero() || item.shares <= 0 {
+			continue
+		}
+		part := need * item.shares * nws[k].NAV / total
+		if trans := item.withdrawShares(part, e.withdraw.TaxRate, nws[k]); trans != nil {
+			got += trans.Amount - trans.TransFee
+			item.drawing.withdrawn += trans.Amount - trans.TransFee
+			item.refresh(nws[k])
+			e.record(item, trans)
+		}
+	}
+	return got
+}
@@ -0,0 +1,147 @@
+package backtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flatNws(start string, days int, nav float32) NetWorthList {
+	var nws NetWorthList
+	date := ParseDate(start)
+	for i := 0; i < days; i++ {
+		nws = append(nws, NetWorth{Date: date.AddDate(0, 0, i), NAV: nav, CNAV: nav})
+	}
+	return nws
+}
+
+func TestEngineWithdrawal(t *testing.T) {
+	nws := flatNws("2020-01-01", 366, 1)
+	s := Strategy{
+		StartDate: ParseDate("2020-01-01"),
+		EndDate:   ParseDate("2020-12-31"),
+		SellRate:  0.5,
+	}
+	e := NewEngine(s, nws)
+	e.SetWithdrawal(Withdrawal{Rule: WithdrawFixed, Initial: 100000, Amount: 995, Day: 1})
+	result := e.Run()
+	assert.InDelta(t, 995*12, result.Withdrawn, 0.1)
+	assert.True(t, result.Depleted.IsZero())
+	last := result.TransList.LastByType(TransWithdraw)
+	assert.InDelta(t, 1000, last.Amount, 0.01)
+	assert.Equal(t, float32(5), last.TransFee)
+	//净值不变时，亏损的只有赎回费
+	assert.InDelta(t, -60, result.Profit, 0.1)
+
+	e = NewEngine(s, flatNws("2020-01-01", 366, 1))
+	e.SetWithdrawal(Withdrawal{Rule: WithdrawFixed, Initial: 5000, Amount: 1000, Day: 1})
+	result = e.Run()
+	//扣除赎回费后第5个月不足1000
+	assert.Equal(t, "2020-05-01", DateToString(result.Depleted))
+}
+
+func TestWithdrawalGuardrail(t *testing.T) {
+	w := Withdrawal{Rule: WithdrawGuardrail, Rate: 4, Upper: 20, Lower: 20, Adjust: 10}
+	var drawing withdrawing
+	assert.InDelta(t, 400, w.monthly(&drawing, ParseDate("2020-01-02"), 120000), 0.01)
+	assert.InDelta(t, 400, w.monthly(&drawing, ParseDate("2020-06-02"), 60000), 0.01)
+	//一年后取出率8%超过护栏上限，削减10%
+	assert.InDelta(t, 360, w.monthly(&drawing, ParseDate("2021-01-04"), 60000), 0.01)
+	//取出率低于下限，提高10%
+	assert.InDelta(t, 396, w.monthly(&drawing, ParseDate("2022-01-04"), 240000), 0.01)
+
+	w = Withdrawal{Rule: WithdrawPercent, Rate: 6}
+	drawing = withdrawing{}
+	assert.InDelta(t, 500, w.monthly(&drawing, ParseDate("2020-01-02"), 100000), 0.01)
+	assert.InDelta(t, 250, w.monthly(&drawing, ParseDate("2020-02-03"), 50000), 0.01)
+}
+
+func TestDepletion(t *testing.T) {
+	var nws NetWorthList
+	date := ParseDate("2015-01-01")
+	nav := float32(1)
+	for i := 0; i < 1000; i++ {
+		roc := float32(i%7-3) * 0.5
+		nav *= 1 + roc/100
+		nws = append(nws, NetWorth{Date: date.AddDate(0, 0, i), NAV: nav, ROC: roc})
+	}
+	paths := Bootstrap(nws, 20, 10, 1)
+	assert.Len(t, paths, 20)
+	assert.Len(t, paths[0], 1000)
+	assert.Equal(t, nws[999].Date, paths[0][999].Date)
+	s := Strategy{StartDate: date, EndDate: date.AddDate(3, 0, 0)}
+	p, results := Depletion(s, Withdrawal{Rule: WithdrawFixed, Initial: 10000, Amount: 1000, Day: 1}, paths)
+	assert.Equal(t, 1.0, p)
+	assert.Len(t, results, 20)
+	p, _ = Depletion(s, Withdrawal{Rule: WithdrawPercent, Initial: 10000, Rate: 4, Day: 1}, paths)
+	assert.Equal(t, 0.0, p)
+}
+
+func TestPackEngineWithdrawal(t *testing.T) {
+	var items PackItemList
+	for _, tof := range []TOF{Radical, Conservative} {
+		items = append(items, PackItem{
+			Engine:  NewEngine(Strategy{CycleType: CycleMonth, CycleValue: 1, MaxAmount: 10000}, flatNws("2020-01-01", 400, 1)),
+			TOF:     tof,
+			Precent: 50,
+		})
+	}
+	e := NewPackEngine(items, ParseDate("2020-01-01"), 0)
+	e.SetEndDate(ParseDate("2020-12-31"))
+	e.SetWithdrawal(Withdrawal{Rule: WithdrawFixed, Initial: 50000, Amount: 1000, Day: 1})
+	result := e.Run()
+	assert.InDelta(t, 12000, result.Withdrawn, 1)
+	assert.InDelta(t, 50000, result.Invest, 0.01)
+	assert.InDelta(t, 38000, result.Value, 1)
+	assert.True(t, result.Depleted.IsZero())
+	assert.Len(t, result.Items, 2)
+	assert.Equal(t, "2020-12-31", DateToString(result.Date))
+}
+
+func TestWithdrawalMonthEnd(t *testing.T) {
+	s := Strategy{StartDate: ParseDate("2021-01-01"), EndDate: ParseDate("2021-12-31")}
+	e := NewEngine(s, flatNws("2021-01-01", 365, 1))
+	e.SetWithdrawal(Withdrawal{Rule: WithdrawFixed, Initial: 100000, Amount: 1000, Day: 31})
+	result := e.Run()
+	var dates []string
+	for _, trans := range result.TransList {
+		if trans.TransType == TransWithdraw {
+			dates = append(dates, DateToString(trans.Date))
+		}
+	}
+	//较短的月份在当月最后一个交易日取出
+	assert.Len(t, dates, 12)
+	assert.Equal(t, "2021-01-29", dates[0])
+	assert.Equal(t, "2021-02-26", dates[1])
+	assert.Equal(t, "2021-03-31", dates[2])
+	assert.Equal(t, "2021-04-30", dates[3])
+	assert.Equal(t, "2021-09-30", dates[8])
+	assert.InDelta(t, 12000, result.Withdrawn, 0.1)
+}
+
+func TestWithdrawalRetry(t *testing.T) {
+	s := Strategy{StartDate: ParseDate("2021-01-01"), EndDate: ParseDate("2021-04-30")}
+	e := NewEngine(s, flatNws("2021-01-01", 120, 1))
+	w := Withdrawal{Rule: WithdrawFixed, Initial: 100000, Amount: 1000, Day: 1}
+	e.SetWithdrawal(w)
+	//判断取出日不改变取出状态
+	date := ParseDate("2021-02-01")
+	assert.True(t, e.isWithdrawDay(date))
+	assert.True(t, e.isWithdrawDay(date))
+
+	//暂停赎回期间未能取出，恢复后在当月取出
+	e.SetAvailability(AvailabilityList{
+		{Date: ParseDate("2021-03-01"), Redeem: StatusSuspended},
+		{Date: ParseDate("2021-03-03"), Redeem: StatusOpen},
+	})
+	result := e.Run()
+	var dates []string
+	for _, trans := range result.TransList {
+		if trans.TransType == TransWithdraw {
+			dates = append(dates, DateToString(trans.Date))
+		}
+	}
+	assert.Equal(t, []string{"2021-01-01", "2021-02-01", "2021-03-03", "2021-04-01"}, dates)
+	assert.InDelta(t, 4000, result.Withdrawn, 0.1)
+	assert.True(t, result.Depleted.IsZero())
+}